@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	velero "vresq/pkg/velero"
+
+	"github.com/spf13/cobra"
+)
+
+var backupsCmd = &cobra.Command{
+	Use:     "backups",
+	Aliases: []string{"backup"},
+	Short:   "Inspect Velero backups in the source cluster",
+	Long: `The "backups" command lists and describes the Velero backups available in the source cluster,
+without requiring the velero CLI to be installed.
+
+Example usage:
+  $ vresq backups list --source-context=<source-context>
+  $ vresq backups describe <backup-name> --output=yaml
+`,
+}
+
+var backupsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List Velero backups in the source cluster",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverSourceVeleroNamespace(); err != nil {
+			return err
+		}
+		backups, err := velero.ListBackups(&sourceDynamiClient, config.SourceVeleroNamespace)
+		if err != nil {
+			return fmt.Errorf("could not list backups in namespace %s: %v", config.SourceVeleroNamespace, err)
+		}
+
+		// Show the most recent backups first
+		sort.SliceStable(backups.Items, func(i, j int) bool {
+			return backups.Items[i].GetCreationTimestamp().After(backups.Items[j].GetCreationTimestamp().Time)
+		})
+
+		summaries := []velero.BackupSummary{}
+		for _, backup := range backups.Items {
+			summaries = append(summaries, velero.GetBackupSummary(backup))
+		}
+
+		return printOutput(os.Stdout, outputFormat, summaries, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "NAME\tPHASE\tSTARTED\tCOMPLETED\tEXPIRES\tSTORAGE LOCATION\tERRORS\tWARNINGS\tINCLUDED NAMESPACES\tEXCLUDED NAMESPACES\tINCLUDED RESOURCES\tEXCLUDED RESOURCES")
+			for _, summary := range summaries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+					summary.Name,
+					valueOrNone(summary.Phase),
+					valueOrNone(summary.StartTimestamp),
+					valueOrNone(summary.CompletionTimestamp),
+					valueOrNone(summary.Expiration),
+					valueOrNone(summary.StorageLocation),
+					summary.Errors,
+					summary.Warnings,
+					joinOrNone(summary.IncludedNamespaces),
+					joinOrNone(summary.ExcludedNamespaces),
+					joinOrNone(summary.IncludedResources),
+					joinOrNone(summary.ExcludedResources),
+				)
+			}
+		})
+	},
+}
+
+var backupsDescribeCmd = &cobra.Command{
+	Use:   "describe <backup-name>",
+	Short: "Describe a Velero backup in the source cluster",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverSourceVeleroNamespace(); err != nil {
+			return err
+		}
+		backup, err := velero.GetBackup(&sourceDynamiClient, config.SourceVeleroNamespace, args[0])
+		if err != nil {
+			return fmt.Errorf("could not get backup %s: %v", args[0], err)
+		}
+		details := velero.GetBackupDetails(backup)
+
+		return printOutput(os.Stdout, outputFormat, details, func(tw *tabwriter.Writer) {
+			fmt.Fprintf(tw, "Name:\t%s\n", details.Name)
+			fmt.Fprintf(tw, "Namespace:\t%s\n", config.SourceVeleroNamespace)
+			fmt.Fprintf(tw, "Phase:\t%s\n", valueOrNone(details.Phase))
+			fmt.Fprintf(tw, "Errors:\t%d\n", details.Errors)
+			fmt.Fprintf(tw, "Warnings:\t%d\n", details.Warnings)
+			fmt.Fprintf(tw, "Started:\t%s\n", valueOrNone(details.StartTimestamp))
+			fmt.Fprintf(tw, "Completed:\t%s\n", valueOrNone(details.CompletionTimestamp))
+			fmt.Fprintf(tw, "Expiration:\t%s\n", valueOrNone(details.Expiration))
+			fmt.Fprintf(tw, "Storage Location:\t%s\n", valueOrNone(details.StorageLocation))
+			fmt.Fprintf(tw, "Included Namespaces:\t%s\n", joinOrNone(details.IncludedNamespaces))
+			fmt.Fprintf(tw, "Excluded Namespaces:\t%s\n", joinOrNone(details.ExcludedNamespaces))
+			fmt.Fprintf(tw, "Included Resources:\t%s\n", joinOrNone(details.IncludedResources))
+			fmt.Fprintf(tw, "Excluded Resources:\t%s\n", joinOrNone(details.ExcludedResources))
+			fmt.Fprintf(tw, "Volume Snapshots:\t%s\n", formatProgress(details.VolumeSnapshotsCompleted, details.VolumeSnapshotsAttempted))
+			fmt.Fprintf(tw, "CSI Volume Snapshots:\t%s\n", formatProgress(details.CSIVolumeSnapshotsCompleted, details.CSIVolumeSnapshotsAttempted))
+			fmt.Fprintf(tw, "Item Operations:\t%s, %d failed\n", formatProgress(details.BackupItemOperationsCompleted, details.BackupItemOperationsAttempted), details.BackupItemOperationsFailed)
+		})
+	},
+}
+
+// formatProgress formats a completed/attempted counter pair.
+func formatProgress(completed int64, attempted int64) string {
+	return fmt.Sprintf("%d of %d completed", completed, attempted)
+}
+
+func init() {
+	addOutputFlag(backupsListCmd)
+	addOutputFlag(backupsDescribeCmd)
+	backupsDescribeCmd.ValidArgsFunction = completeBackupNameArg
+	backupsCmd.AddCommand(backupsListCmd)
+	backupsCmd.AddCommand(backupsDescribeCmd)
+	rootCmd.AddCommand(backupsCmd)
+}
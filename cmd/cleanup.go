@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	kube "vresq/pkg/kubernetes"
+	prompt "vresq/pkg/prompt"
+	velero "vresq/pkg/velero"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanupRunID   string
+	confirmCleanup bool
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Remove the objects vresq created or modified in the destination cluster",
+	Long: `The "cleanup" command lists the objects vresq created or modified in the destination Velero namespace, then removes them:
+  - the read-only BackupStorageLocation, along with the Backup objects Velero synchronized from it,
+  - its credentials Secret,
+  - the VolumeSnapshotLocations cloned from the source cluster, along with their credentials Secrets,
+  - the storage class, node selector and image name ConfigMaps, whose keys modified by the runs get their previous values back when they existed before vresq modified them,
+  - the Velero Helm release cloned from the source cluster.
+
+Every object vresq creates is labelled with "app.kubernetes.io/managed-by=vresq" and "vresq.avisto.com/run-id=<run-id>".
+The run ID is logged at the beginning of each run, use --run-id to only clean up the objects of that run.
+Use --dry-run to only list the objects.
+
+Example usage:
+  $ vresq cleanup --destination-context=<destination-context> --run-id=<run-id>
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverDestinationVeleroNamespace(); err != nil {
+			return err
+		}
+		helmClient := kube.GetHelmClientWithContext(config.DestinationKubeconfig, config.DestinationContext, config.DestinationVeleroNamespace)
+
+		resources, err := velero.ListManagedResources(&destinationDynamiClient, helmClient, config.DestinationVeleroNamespace, cleanupRunID)
+		if err != nil {
+			return err
+		}
+		if len(resources) == 0 {
+			log.Println("Nothing to clean up")
+			return nil
+		}
+		err = printOutput(os.Stdout, outputFormat, resources, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tRUN ID\tACTION")
+			for _, resource := range resources {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", resource.Kind, resource.Namespace, resource.Name, valueOrNone(resource.RunID), resource.Action)
+			}
+		})
+		if err != nil || config.DryRun {
+			return err
+		}
+
+		confirmed := confirmCleanup || config.AssumeYes
+		if !confirmed && config.NonInteractive {
+			return errors.New("cleaning up requires --confirm in non-interactive mode")
+		}
+		if !confirmed && !prompt.ConfirmUserChoice(fmt.Sprintf("Do you confirm cleaning up these %d objects", len(resources))) {
+			log.Println("Cleanup cancelled")
+			return nil
+		}
+
+		// Keep going on errors so that one stuck object does not prevent cleaning up the others
+		var errs []error
+		for _, resource := range resources {
+			if err := velero.CleanupManagedResource(&destinationDynamiClient, helmClient, resource); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			log.Printf("%s %s/%s: %s done", resource.Kind, resource.Namespace, resource.Name, resource.Action)
+		}
+		return errors.Join(errs...)
+	},
+}
+
+func init() {
+	addOutputFlag(cleanupCmd)
+	cleanupCmd.Flags().StringVar(&cleanupRunID, "run-id", "", "Only clean up the objects created or modified by this run")
+	cleanupCmd.Flags().BoolVar(&confirmCleanup, "confirm", false, "Clean up without asking for confirmation")
+	rootCmd.AddCommand(cleanupCmd)
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	prompt "vresq/pkg/prompt"
+	velero "vresq/pkg/velero"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// existingResourcePolicies lists the values Velero accepts for the existing resource policy of a restore.
+var existingResourcePolicies = []string{"none", "update"}
+
+// prepareCompletion loads the configuration for a completion request and sets up the Kubernetes clients.
+// Logs are discarded, since completion output must only contain the suggestions.
+func prepareCompletion(cmd *cobra.Command) error {
+	log.SetOutput(io.Discard)
+	if err := initConfig(cmd); err != nil {
+		return err
+	}
+	setupKubernetesClients()
+	return nil
+}
+
+// completeSourceContexts suggests the contexts of the source kubeconfig.
+func completeSourceContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	log.SetOutput(io.Discard)
+	if err := initConfig(cmd); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	kubeconfig := config.SourceKubeconfig
+	if kubeconfig == "" {
+		kubeconfig = defaultSourceKubeconfig
+	}
+	return completeKubeconfigContexts(kubeconfig)
+}
+
+// completeDestinationContexts suggests the contexts of the destination kubeconfig, which defaults to the source one.
+func completeDestinationContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	log.SetOutput(io.Discard)
+	if err := initConfig(cmd); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	kubeconfig := config.DestinationKubeconfig
+	if kubeconfig == "" {
+		kubeconfig = config.SourceKubeconfig
+	}
+	if kubeconfig == "" {
+		kubeconfig = defaultSourceKubeconfig
+	}
+	return completeKubeconfigContexts(kubeconfig)
+}
+
+// completeKubeconfigContexts suggests the contexts of the given kubeconfig.
+func completeKubeconfigContexts(kubeconfig string) ([]string, cobra.ShellCompDirective) {
+	contexts, err := prompt.GetKubeconfigContextNames(kubeconfig)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return contexts, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeBackupNames suggests the backups of the source cluster, most recent first, described by their phase and completion time.
+func completeBackupNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if err := prepareCompletion(cmd); err != nil || discoverSourceVeleroNamespace() != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	backups, err := velero.ListBackups(&sourceDynamiClient, config.SourceVeleroNamespace)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	sortByCreationDesc(backups.Items)
+
+	completions := []string{}
+	for _, backup := range backups.Items {
+		summary := velero.GetBackupSummary(backup)
+		completions = append(completions, fmt.Sprintf("%s\t%s, completed %s", summary.Name, valueOrNone(summary.Phase), valueOrNone(summary.CompletionTimestamp)))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// completeBackupNameArg suggests the backups of the source cluster for commands taking one backup name.
+func completeBackupNameArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeBackupNames(cmd, args, toComplete)
+}
+
+// completeScheduleNames suggests the schedules of the source cluster.
+func completeScheduleNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if err := prepareCompletion(cmd); err != nil || discoverSourceVeleroNamespace() != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	schedules, err := velero.ListSchedules(&sourceDynamiClient, config.SourceVeleroNamespace)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	completions := []string{}
+	for _, schedule := range schedules.Items {
+		cron, _, _ := unstructured.NestedString(schedule.Object, "spec", "schedule")
+		completions = append(completions, fmt.Sprintf("%s\t%s", schedule.GetName(), valueOrNone(cron)))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeIncludedNamespaces suggests the namespaces included in the chosen backup, leaving out those already given.
+func completeIncludedNamespaces(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if err := prepareCompletion(cmd); err != nil || discoverSourceVeleroNamespace() != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	if config.VeleroRestoreOptions.BackupName == "" && config.VeleroRestoreOptions.ScheduleName != "" {
+		if err := resolveScheduleBackup(); err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+	}
+	if config.VeleroRestoreOptions.BackupName == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	backup, err := velero.GetBackup(&sourceDynamiClient, config.SourceVeleroNamespace, config.VeleroRestoreOptions.BackupName)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	// The flag is a comma separated list, so only the last element is being completed
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+	}
+	given := map[string]bool{}
+	for _, namespace := range strings.Split(prefix, ",") {
+		given[namespace] = true
+	}
+	completions := []string{}
+	for _, namespace := range velero.GetBackupSummary(backup).IncludedNamespaces {
+		if namespace == "*" || given[namespace] {
+			continue
+		}
+		completions = append(completions, prefix+namespace)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeExistingResourcePolicies suggests the values Velero accepts for the existing resource policy.
+func completeExistingResourcePolicies(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return existingResourcePolicies, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeNamespaceConflictPolicies suggests the policies applied to the conflicting destination namespaces.
+func completeNamespaceConflictPolicies(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{velero.NamespaceConflictFail, velero.NamespaceConflictSkip, velero.NamespaceConflictMerge}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeOutputFormats suggests the supported output formats.
+func completeOutputFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{outputTable, outputJSON, outputYAML}, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRestoreNames suggests the restores of the destination cluster, most recent first, for commands taking one restore name.
+func completeRestoreNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if err := prepareCompletion(cmd); err != nil || discoverDestinationVeleroNamespace() != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	restores, err := velero.ListRestores(&destinationDynamiClient, config.DestinationVeleroNamespace)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	sortByCreationDesc(restores.Items)
+
+	completions := []string{}
+	for _, restore := range restores.Items {
+		summary := velero.GetRestoreSummary(restore)
+		completions = append(completions, fmt.Sprintf("%s\t%s, backup %s", summary.Name, valueOrNone(summary.Phase), valueOrNone(summary.BackupName)))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+// sortByCreationDesc sorts objects from the most recent to the oldest.
+func sortByCreationDesc(objects []unstructured.Unstructured) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].GetCreationTimestamp().After(objects[j].GetCreationTimestamp().Time)
+	})
+}
+
+// registerFlagCompletions registers the dynamic completion of the root command flags.
+// It must be called once the flags are defined.
+func registerFlagCompletions() {
+	rootCmd.RegisterFlagCompletionFunc("source-context", completeSourceContexts)
+	rootCmd.RegisterFlagCompletionFunc("destination-context", completeDestinationContexts)
+	rootCmd.RegisterFlagCompletionFunc("backup-name", completeBackupNames)
+	rootCmd.RegisterFlagCompletionFunc("schedule-name", completeScheduleNames)
+	rootCmd.RegisterFlagCompletionFunc("included-namespaces", completeIncludedNamespaces)
+	rootCmd.RegisterFlagCompletionFunc("existing-resource-policy", completeExistingResourcePolicies)
+	rootCmd.RegisterFlagCompletionFunc("on-namespace-conflict", completeNamespaceConflictPolicies)
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+}
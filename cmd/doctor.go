@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	kube "vresq/pkg/kubernetes"
+	velero "vresq/pkg/velero"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	clusterSource      = "source"
+	clusterDestination = "destination"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the source and destination clusters are ready for a restore",
+	Long: `The "doctor" command runs the preflight checks of a restore on the source and destination clusters:
+  - the Velero CRDs are served and a Velero server pod is running,
+  - the source BackupStorageLocation is Available, only the one of --backup-name when given,
+  - the source Velero server mounts the 'cloud-credentials' volume and its Secret exists,
+  - the source Velero Helm release can be discovered, to clone Velero in the destination cluster,
+  - the destination cluster has a default StorageClass, only a warning with --storage-class-mapping.
+
+Each check passes, warns or fails with a remediation hint. The command exits with a non-zero code when a check fails.
+A missing Velero in the destination cluster is only a warning, since vresq can clone it from the source cluster.
+
+Example usage:
+  $ vresq doctor --source-context=<source-context> --destination-context=<destination-context>
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		results := runDoctorChecks()
+
+		err := printOutput(os.Stdout, outputFormat, results, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "CLUSTER\tCHECK\tSTATUS\tMESSAGE")
+			for _, result := range results {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Cluster, result.Name, result.Status, result.Message)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, result := range results {
+			if result.Hint != "" && outputFormat == outputTable {
+				log.Printf("Hint (%s, %s): %s", result.Cluster, result.Name, result.Hint)
+			}
+			if result.Status == velero.CheckFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+// runDoctorChecks runs the preflight checks on the source and destination clusters.
+func runDoctorChecks() []velero.CheckResult {
+	results := []velero.CheckResult{}
+
+	// Source cluster: Velero must be running there, since the backups are read from it
+	results = append(results, velero.CheckVeleroCRDs(&sourceDynamiClient, clusterSource, velero.CheckFail))
+	serverResult, sourceVeleroPod := velero.CheckVeleroServer(&sourceDynamiClient, clusterSource, velero.CheckFail)
+	results = append(results, serverResult)
+	if config.SourceVeleroNamespace == "" && sourceVeleroPod != nil {
+		config.SourceVeleroNamespace = sourceVeleroPod.GetNamespace()
+	}
+	if config.SourceVeleroNamespace != "" {
+		results = append(results, velero.CheckBackupStorageLocations(&sourceDynamiClient, clusterSource, config.SourceVeleroNamespace, config.VeleroRestoreOptions.BackupName)...)
+		sourceHelmClient := kube.GetHelmClientWithContext(config.SourceKubeconfig, config.SourceContext, config.SourceVeleroNamespace)
+		results = append(results, velero.CheckHelmRelease(sourceHelmClient, clusterSource, config.SourceVeleroHelmReleaseName))
+	}
+	if sourceVeleroPod != nil {
+		results = append(results, velero.CheckVeleroCredentials(&sourceDynamiClient, clusterSource, sourceVeleroPod)...)
+	}
+
+	// Destination cluster: Velero can be cloned from the source cluster, so its absence is only a warning
+	results = append(results, velero.CheckVeleroCRDs(&destinationDynamiClient, clusterDestination, velero.CheckWarn))
+	serverResult, _ = velero.CheckVeleroServer(&destinationDynamiClient, clusterDestination, velero.CheckWarn)
+	results = append(results, serverResult)
+	// Without a default StorageClass, the storage classes can still be mapped explicitly
+	defaultStorageClassStatus := velero.CheckFail
+	if len(config.StorageClassMapping) > 0 {
+		defaultStorageClassStatus = velero.CheckWarn
+	}
+	results = append(results, velero.CheckDefaultStorageClass(&destinationDynamiClient, clusterDestination, defaultStorageClassStatus))
+	return results
+}
+
+func init() {
+	addOutputFlag(doctorCmd)
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	velero "vresq/pkg/velero"
+
+	"github.com/spf13/cobra"
+)
+
+var exportDir string
+
+// addExportFlag registers the --export-dir flag on commands running the restore workflow.
+func addExportFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&exportDir, "export-dir", "", "directory where the equivalent velero command and the Restore manifest are written")
+}
+
+// veleroRestoreCommand returns the "velero restore create" command equivalent to the Restore of the current run.
+func veleroRestoreCommand() string {
+	return velero.FormatCommand(velero.VeleroRestoreCreateArgs(config.DestinationVeleroNamespace, config.RestoreName, config.VeleroRestoreOptions))
+}
+
+// exportRestore writes the equivalent "velero restore create" command and the Restore manifest of the current run in the export directory,
+// along with the manifest of the resource modifiers ConfigMap the restore refers to, if any.
+func exportRestore(resourceModifiers velero.ResourceModifiers) error {
+	if err := os.MkdirAll(exportDir, 0o755); err != nil {
+		return fmt.Errorf("could not create export directory %s: %v", exportDir, err)
+	}
+
+	commandPath := filepath.Join(exportDir, fmt.Sprintf("%s-velero-command.sh", config.RestoreName))
+	manifestPath := filepath.Join(exportDir, fmt.Sprintf("%s-restore.yaml", config.RestoreName))
+	resourceModifiersPath := filepath.Join(exportDir, fmt.Sprintf("%s.yaml", velero.ResourceModifiersConfigMapName(config.RestoreName)))
+	header := fmt.Sprintf("# Restore %s, equivalent to the vresq run %s\n", config.RestoreName, velero.GetRunID())
+	if velero.HasResourceModifiers(config.VeleroRestoreOptions) {
+		// The velero CLI only refers to the ConfigMap, which must exist before the restore is created
+		header += fmt.Sprintf("# Warning: the resource modifiers ConfigMap must exist, apply %s first\n", filepath.Base(resourceModifiersPath))
+	}
+	if len(config.VeleroRestoreOptions.RestoreHooks) > 0 {
+		// The velero CLI has no flag for restore hooks
+		header += fmt.Sprintf("# Warning: the restore hooks cannot be given to velero restore create, apply %s instead\n", filepath.Base(manifestPath))
+	}
+	command := fmt.Sprintf("#!/bin/sh\n%s%s\n", header, veleroRestoreCommand())
+	if err := os.WriteFile(commandPath, []byte(command), 0o755); err != nil {
+		return fmt.Errorf("could not write velero command to %s: %v", commandPath, err)
+	}
+
+	restore := velero.BuildVeleroRestore(config.DestinationVeleroNamespace, config.RestoreName, config.VeleroRestoreOptions)
+	if err := writeManifest(manifestPath, restore.Object); err != nil {
+		return fmt.Errorf("could not write Restore manifest to %s: %v", manifestPath, err)
+	}
+	log.Printf("Equivalent velero command written to %s, Restore manifest written to %s", commandPath, manifestPath)
+
+	if velero.HasResourceModifiers(config.VeleroRestoreOptions) {
+		configMap, err := velero.BuildResourceModifiersConfigMap(config.DestinationVeleroNamespace, config.RestoreName, resourceModifiers)
+		if err != nil {
+			return err
+		}
+		if err := writeManifest(resourceModifiersPath, configMap.Object); err != nil {
+			return fmt.Errorf("could not write resource modifiers ConfigMap manifest to %s: %v", resourceModifiersPath, err)
+		}
+		log.Printf("Resource modifiers ConfigMap manifest written to %s", resourceModifiersPath)
+	}
+	return nil
+}
+
+// writeManifest writes an object as a YAML manifest to the given path.
+func writeManifest(path string, object map[string]interface{}) error {
+	manifestFile, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", path, err)
+	}
+	defer manifestFile.Close()
+	return printOutput(manifestFile, outputYAML, object, nil)
+}
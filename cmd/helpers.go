@@ -1,16 +1,21 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
 	"strings"
 	"time"
+	common "vresq/pkg/common"
+	kube "vresq/pkg/kubernetes"
+	velero "vresq/pkg/velero"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 // setDefaultSourceKubeconfig sets the default kubeconfig path based on the user's home directory and OS.
@@ -18,7 +23,7 @@ func setDefaultSourceKubeconfig() {
 	// Get the user's home directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Printf("Error getting user's home directory: %v\n", err)
+		log.Printf("Error getting user's home directory: %v", err)
 		return
 	}
 
@@ -29,10 +34,74 @@ func setDefaultSourceKubeconfig() {
 	case "linux":
 		defaultSourceKubeconfig = homeDir + "/.kube/config"
 	default:
-		fmt.Printf("Unsupported operating system: %s\n", runtime.GOOS)
+		log.Printf("Unsupported operating system: %s", runtime.GOOS)
 	}
 }
 
+// newCurrentContext describes how the source and destination kubeconfigs and contexts relate to each other.
+func newCurrentContext() kube.CurrentContext {
+	return kube.CurrentContext{
+		SameOrOnlySourceKubeconfig: config.DestinationKubeconfig == "" || config.DestinationKubeconfig == config.SourceKubeconfig,
+		SameOrOnlySourceContext:    config.DestinationContext == "" || config.DestinationContext == config.SourceContext,
+		NoGivenContext:             config.DestinationContext == "" && config.SourceContext == "",
+	}
+}
+
+// setupKubernetesClients sets up the source and destination clients without prompting.
+// The default kubeconfig is used when no source kubeconfig is given, and the destination defaults to the source.
+func setupKubernetesClients() kube.CurrentContext {
+	if config.SourceKubeconfig == "" {
+		config.SourceKubeconfig = defaultSourceKubeconfig
+	}
+	if config.DestinationKubeconfig == "" {
+		config.DestinationKubeconfig = config.SourceKubeconfig
+		if config.DestinationContext == "" {
+			config.DestinationContext = config.SourceContext
+		}
+	}
+	currentContext := newCurrentContext()
+	kube.SetupSourceAndDestinationKubernetesClients(&sourceDynamiClient, &destinationDynamiClient, &currentContext, &config)
+	return currentContext
+}
+
+// discoverSourceVeleroNamespace detects the source Velero namespace from the Velero server pod when it is not given.
+func discoverSourceVeleroNamespace() error {
+	if config.SourceVeleroNamespace != "" {
+		return nil
+	}
+	veleroPod, err := velero.GetVeleroPod(&sourceDynamiClient)
+	if err != nil {
+		return fmt.Errorf("could not discover source velero namespace. Please specify one. %v", err)
+	}
+	config.SourceVeleroNamespace = veleroPod.GetNamespace()
+	return nil
+}
+
+// discoverDestinationVeleroNamespace detects the destination Velero namespace from the Velero server pod when it is not given.
+func discoverDestinationVeleroNamespace() error {
+	if config.DestinationVeleroNamespace != "" {
+		return nil
+	}
+	veleroPod, err := velero.GetVeleroPod(&destinationDynamiClient)
+	if err != nil {
+		return fmt.Errorf("could not discover destination velero namespace. Please specify one. %v", err)
+	}
+	config.DestinationVeleroNamespace = veleroPod.GetNamespace()
+	return nil
+}
+
+// resolveScheduleBackup sets the backup name to the most recent successful backup of the configured schedule in the source cluster.
+func resolveScheduleBackup() error {
+	backup, err := velero.GetLatestScheduleBackup(&sourceDynamiClient, config.SourceVeleroNamespace, config.VeleroRestoreOptions.ScheduleName, config.VeleroRestoreOptions.AllowPartiallyFailed)
+	if err != nil {
+		return fmt.Errorf("could not resolve schedule %s to a backup: %v", config.VeleroRestoreOptions.ScheduleName, err)
+	}
+	config.VeleroRestoreOptions.BackupName = backup.GetName()
+	summary := velero.GetBackupSummary(backup)
+	log.Printf("Using backup %s (phase %s, completed %s), the most recent backup of schedule %s", summary.Name, summary.Phase, valueOrNone(summary.CompletionTimestamp), config.VeleroRestoreOptions.ScheduleName)
+	return nil
+}
+
 // bindFlags binds flags to their corresponding values in the viper configuration.
 func bindFlags(cmd *cobra.Command, v *viper.Viper) {
 	cmd.Flags().VisitAll(func(f *pflag.Flag) {
@@ -45,14 +114,59 @@ func bindFlags(cmd *cobra.Command, v *viper.Viper) {
 		}
 		// Apply the viper config value to the flag when the flag is not set and viper has a value
 		if !f.Changed && v.IsSet(configName) {
-			val := v.Get(configName)
-			cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
+			// Array flags, unlike slice flags, do not split their values on commas, so every item is set on its own
+			if f.Value.Type() == "stringArray" {
+				for _, item := range flagValues(v.Get(configName)) {
+					cmd.Flags().Set(f.Name, item)
+				}
+				return
+			}
+			cmd.Flags().Set(f.Name, flagValue(v.Get(configName)))
 		}
 	})
 }
 
-// initConfig reads in config file and ENV variables if set.
-func initConfig(cmd *cobra.Command) error {
+// flagValue formats a value of the config file as a flag value.
+// Lists are formatted as comma separated values and maps as comma separated key=value pairs, as slice and map flags expect.
+func flagValue(value interface{}) string {
+	if values, ok := value.([]interface{}); ok {
+		items := []string{}
+		for _, item := range values {
+			items = append(items, fmt.Sprintf("%v", item))
+		}
+		return strings.Join(items, ",")
+	}
+	if values := toStringMap(value); values != nil {
+		pairs := []string{}
+		for _, key := range sortedMapKeys(values) {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", key, values[key]))
+		}
+		return strings.Join(pairs, ",")
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// flagValues formats a value of the config file as the values of an array flag: one per item of a list, or one per key=value pair of a map.
+func flagValues(value interface{}) []string {
+	if values, ok := value.([]interface{}); ok {
+		items := []string{}
+		for _, item := range values {
+			items = append(items, fmt.Sprintf("%v", item))
+		}
+		return items
+	}
+	if values := toStringMap(value); values != nil {
+		pairs := []string{}
+		for _, key := range sortedMapKeys(values) {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", key, values[key]))
+		}
+		return pairs
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
+
+// readConfigFile initializes viper and reads the config file, if any.
+func readConfigFile() (*viper.Viper, error) {
 	// Initialize viper configuration
 	v := viper.New()
 	v.SetConfigName(defaultConfigFilename)
@@ -70,9 +184,19 @@ func initConfig(cmd *cobra.Command) error {
 	if err := v.ReadInConfig(); err != nil {
 		// It's okay if there isn't a config file
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return err
+			return nil, err
 		}
 	}
+	return v, nil
+}
+
+// initConfig reads in config file and ENV variables if set.
+// When a profile is selected, its values are merged over the top-level values of the config file.
+func initConfig(cmd *cobra.Command) error {
+	v, err := readConfigFile()
+	if err != nil {
+		return err
+	}
 
 	// Set default values for configuration parameters
 	v.SetDefault("source-context", "")
@@ -84,6 +208,7 @@ func initConfig(cmd *cobra.Command) error {
 	v.SetDefault("destination-velero-namespace", "")
 	v.SetDefault("backup-name", "")
 	v.SetDefault("schedule-name", "")
+	v.SetDefault("allow-partially-failed", false)
 	v.SetDefault("item-operation-timeout", 4*time.Hour)
 	v.SetDefault("included-namespaces", "")
 	v.SetDefault("excluded-namespaces", "")
@@ -91,21 +216,132 @@ func initConfig(cmd *cobra.Command) error {
 	v.SetDefault("excluded-resources", "")
 	v.SetDefault("include-cluster-resources", false)
 	v.SetDefault("label-selector", "")
-	v.SetDefault("or-label-selectors", "")
+	v.SetDefault("or-label-selectors", []interface{}{})
 	v.SetDefault("namespace-mapping", "")
+	v.SetDefault("namespace-mapping-rule", []interface{}{})
+	v.SetDefault("on-namespace-conflict", "")
+	v.SetDefault("storage-class-mapping", "")
+	v.SetDefault("storage-class-configmap", common.ConfigMapName)
+	v.SetDefault("keep-storage-class-mapping", false)
+	v.SetDefault("node-mapping", "")
+	v.SetDefault("node-selector-configmap", common.NodeSelectorConfigMapName)
+	v.SetDefault("keep-node-mapping", false)
+	v.SetDefault("image-name-rule", []interface{}{})
+	v.SetDefault("image-name-configmap", common.ImageNameConfigMapName)
+	v.SetDefault("keep-image-name-rules", false)
 	v.SetDefault("restore-pvs", true)
 	v.SetDefault("preserve-node-ports", true)
 	v.SetDefault("existing-resource-policy", "none")
+	v.SetDefault("non-interactive", false)
+	v.SetDefault("yes", false)
+	v.SetDefault("clone-velero", false)
+	v.SetDefault("use-source-kubeconfig", false)
+	v.SetDefault("dry-run", false)
+	v.SetDefault("restore-logs-dir", "")
+	v.SetDefault("insecure-skip-tls-verify", false)
+	v.SetDefault("cacert", "")
+	v.SetDefault("resource-modifier-file", "")
+	v.SetDefault("profile", "")
+	v.SetDefault("verify", false)
+	v.SetDefault("verify-timeout", 10*time.Minute)
 	v.SetEnvPrefix(envPrefix)
 
 	// Bind environment variables
 	v.AutomaticEnv()
+
+	// The profile is selected with the flag, the environment variable or the config file, in that order
+	profile := v.GetString("profile")
+	if flag := cmd.Flags().Lookup("profile"); flag != nil && flag.Changed {
+		profile = flag.Value.String()
+	}
+	if profile != "" {
+		if err := applyProfile(v, profile); err != nil {
+			return err
+		}
+		log.Printf("Using profile %s", profile)
+	}
+
 	bindFlags(cmd, v)
+	// Bind the flags themselves so that values given on the command line take precedence when unmarshalling
+	if err := v.BindPFlags(cmd.Flags()); err != nil {
+		return err
+	}
 
 	// Unmarshal configuration into a struct
-	err := v.Unmarshal(&config)
+	err = v.Unmarshal(&config)
 	if err != nil {
 		log.Fatalf("Error: could not read configuration, %v", err)
 	}
+	// Restore hooks and resource modifiers are top-level keys of the config file, but their fields are nested in
+	// VeleroRestoreOptions, which Unmarshal only decodes from a "veleroRestoreOptions" key: they must be read explicitly
+	if err := v.UnmarshalKey("restore-hooks", &config.VeleroRestoreOptions.RestoreHooks); err != nil {
+		log.Fatalf("Error: could not read restore hooks, %v", err)
+	}
+	if err := v.UnmarshalKey("resource-modifiers", &config.VeleroRestoreOptions.ResourceModifiers); err != nil {
+		log.Fatalf("Error: could not read resource modifiers, %v", err)
+	}
+
+	// Prompts cannot work without a terminal, for example in CI
+	if !config.NonInteractive && !term.IsTerminal(int(os.Stdin.Fd())) {
+		log.Println("Info: stdin is not a terminal, running in non-interactive mode")
+		config.NonInteractive = true
+	}
+	if config.AssumeYes {
+		config.CloneVelero = true
+		config.UseSourceKubeconfig = true
+	}
+	return nil
+}
+
+// checkNonInteractiveConfig returns an error listing every required value that would otherwise be prompted.
+func checkNonInteractiveConfig() error {
+	var errs []error
+	if config.DestinationKubeconfig == "" && !config.UseSourceKubeconfig {
+		errs = append(errs, errors.New("no destination kubeconfig: set --destination-kubeconfig, or --use-source-kubeconfig to restore in the source cluster"))
+	}
+	if config.RestoreName == "" {
+		errs = append(errs, errors.New("no restore name: set --restore-name"))
+	}
+	if config.VeleroRestoreOptions.BackupName == "" && config.VeleroRestoreOptions.ScheduleName == "" {
+		errs = append(errs, errors.New("no backup: set --backup-name or --schedule-name"))
+	}
+	if len(config.VeleroRestoreOptions.IncludedNamespaces) == 0 {
+		errs = append(errs, errors.New("no namespaces to restore: set --included-namespaces"))
+	}
+	if len(config.VeleroRestoreOptions.NamespaceMapping) == 0 && len(config.NamespaceMappingRules) == 0 {
+		errs = append(errs, errors.New("no namespace mapping: set --namespace-mapping or --namespace-mapping-rule"))
+	}
+	return errors.Join(errs...)
+}
+
+// expandNamespaceMappingRules maps the included namespaces without an explicit mapping with the namespace mapping rules.
+// When every namespace is included with "*", the rules apply to the namespaces of the backup.
+func expandNamespaceMappingRules(rules []velero.NamespaceMappingRule) error {
+	namespaces := config.VeleroRestoreOptions.IncludedNamespaces
+	for _, namespace := range config.VeleroRestoreOptions.IncludedNamespaces {
+		if namespace != "*" {
+			continue
+		}
+		options := downloadOptions()
+		options.Timeout = backupContentsTimeout
+		backupNamespaces, err := velero.GetBackupNamespaces(&sourceDynamiClient, config.SourceVeleroNamespace, config.VeleroRestoreOptions.BackupName,
+			config.VeleroRestoreOptions.ExcludedNamespaces, options)
+		if err != nil {
+			return fmt.Errorf("could not read the namespaces of backup %s to apply the namespace mapping rules: %v", config.VeleroRestoreOptions.BackupName, err)
+		}
+		namespaces = backupNamespaces
+		break
+	}
+	mapping, err := velero.ExpandNamespaceMapping(rules, namespaces, config.VeleroRestoreOptions.NamespaceMapping,
+		config.RestoreName, config.VeleroRestoreOptions.BackupName)
+	if err != nil {
+		return err
+	}
+	for _, source := range sortedKeys(mapping) {
+		if _, explicit := config.VeleroRestoreOptions.NamespaceMapping[source]; !explicit {
+			log.Printf("Namespace %s mapped to %s by the namespace mapping rules", source, mapping[source])
+		}
+	}
+	config.VeleroRestoreOptions.NamespaceMapping = mapping
 	return nil
 }
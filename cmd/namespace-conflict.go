@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	kube "vresq/pkg/kubernetes"
+	prompt "vresq/pkg/prompt"
+	velero "vresq/pkg/velero"
+
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	conflictRename = "Rename the destination namespace"
+	conflictSkip   = "Skip this namespace"
+	conflictWait   = "Wait for the namespace to be deleted"
+	conflictMerge  = "Merge into the namespace, with existing-resource-policy update"
+	// namespaceDeletionTimeout is the time to wait for a terminating destination namespace to be deleted.
+	namespaceDeletionTimeout = 10 * time.Minute
+)
+
+// resolveNamespaceConflicts checks the destination namespaces of the restore, and resolves the conflicts with the ones
+// which hold objects or are being deleted: with --on-namespace-conflict when given, otherwise by prompting.
+// In non-interactive mode, conflicts fail the run unless a policy is given.
+func resolveNamespaceConflicts() error {
+	for _, namespace := range config.VeleroRestoreOptions.IncludedNamespaces {
+		if namespace == "*" {
+			log.Println("Warning: only the namespaces given with --included-namespaces are checked for conflicts in the destination cluster, not \"*\"")
+		}
+	}
+	discoveryClient := kube.GetDiscoveryClientWithContext(config.DestinationKubeconfig, config.DestinationContext)
+	states, err := velero.GetNamespaceStates(&destinationDynamiClient, discoveryClient, config.VeleroRestoreOptions.IncludedNamespaces, config.VeleroRestoreOptions.NamespaceMapping)
+	if err != nil {
+		return fmt.Errorf("could not check the destination namespaces: %v", err)
+	}
+
+	policy := config.OnNamespaceConflict
+	if policy == "" && config.NonInteractive {
+		policy = velero.NamespaceConflictFail
+	}
+	var errs []error
+	for _, state := range states {
+		log.Printf("Namespace %s is restored in namespace %s: %s", state.Source, state.Target, describeNamespaceState(state))
+		if !state.Conflicting() {
+			continue
+		}
+		if err := resolveNamespaceConflict(discoveryClient, state, policy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if len(config.VeleroRestoreOptions.IncludedNamespaces) == 0 {
+		return errors.New("every namespace was skipped, nothing to restore")
+	}
+	// Renamed namespaces could collide with other destination namespaces
+	if err := velero.ValidateNamespaceMapping(config.VeleroRestoreOptions.NamespaceMapping, config.VeleroRestoreOptions.IncludedNamespaces); err != nil {
+		return fmt.Errorf("invalid namespace mapping:\n%v", err)
+	}
+	return nil
+}
+
+// resolveNamespaceConflict applies the policy to a conflicting destination namespace, or prompts for a resolution when there is none.
+func resolveNamespaceConflict(discoveryClient discovery.DiscoveryInterface, state velero.NamespaceState, policy string) error {
+	for {
+		action := ""
+		switch policy {
+		case velero.NamespaceConflictFail:
+			return fmt.Errorf("destination namespace %s of namespace %s is %s, use --on-namespace-conflict=skip or merge, or another namespace mapping",
+				state.Target, state.Source, describeNamespaceState(state))
+		case velero.NamespaceConflictSkip:
+			action = conflictSkip
+		case velero.NamespaceConflictMerge:
+			// Objects cannot be restored in a namespace being deleted, so wait for it to be recreated by Velero
+			action = conflictMerge
+			if state.Status == velero.NamespaceTerminating {
+				action = conflictWait
+			}
+		default:
+			choices := []string{conflictRename, conflictSkip}
+			if state.Status == velero.NamespaceTerminating {
+				choices = append(choices, conflictWait)
+			} else {
+				choices = append(choices, conflictMerge)
+			}
+			action = prompt.SelectChoice(fmt.Sprintf("Destination namespace %s of namespace %s is %s, what do you want to do", state.Target, state.Source, describeNamespaceState(state)), choices)
+		}
+
+		switch action {
+		case conflictSkip:
+			skipNamespace(state.Source)
+			log.Printf("Namespace %s skipped, its destination namespace %s is %s", state.Source, state.Target, describeNamespaceState(state))
+			return nil
+		case conflictMerge:
+			if config.VeleroRestoreOptions.ExistingResourcePolicy != "update" {
+				log.Printf("Namespace %s merged into namespace %s, existing-resource-policy set to update", state.Source, state.Target)
+				config.VeleroRestoreOptions.ExistingResourcePolicy = "update"
+			}
+			return nil
+		case conflictWait:
+			if config.DryRun {
+				log.Printf("Dry run: not waiting for namespace %s to be deleted", state.Target)
+				return nil
+			}
+			return velero.WaitForNamespaceDeletion(&destinationDynamiClient, state.Target, namespaceDeletionTimeout)
+		case conflictRename:
+			label := fmt.Sprintf("Destination namespace for namespace '%s' restoration :", state.Source)
+			target, err := prompt.NamespaceInput(label, fmt.Sprintf("%s-%s", config.RestoreName, state.Source))
+			if err != nil {
+				return fmt.Errorf("could not rename destination namespace %s: %v", state.Target, err)
+			}
+			config.VeleroRestoreOptions.NamespaceMapping[state.Source] = target
+
+			// The new destination namespace can conflict as well
+			newState, err := velero.GetNamespaceState(&destinationDynamiClient, discoveryClient, target)
+			if err != nil {
+				return fmt.Errorf("could not check destination namespace %s: %v", target, err)
+			}
+			newState.Source = state.Source
+			log.Printf("Namespace %s is restored in namespace %s: %s", newState.Source, newState.Target, describeNamespaceState(newState))
+			if !newState.Conflicting() {
+				return nil
+			}
+			state = newState
+		}
+	}
+}
+
+// skipNamespace removes a namespace from the included namespaces and the namespace mapping.
+func skipNamespace(namespace string) {
+	included := []string{}
+	for _, includedNamespace := range config.VeleroRestoreOptions.IncludedNamespaces {
+		if includedNamespace != namespace {
+			included = append(included, includedNamespace)
+		}
+	}
+	config.VeleroRestoreOptions.IncludedNamespaces = included
+	delete(config.VeleroRestoreOptions.NamespaceMapping, namespace)
+}
+
+// describeNamespaceState describes the state of a destination namespace in a sentence.
+func describeNamespaceState(state velero.NamespaceState) string {
+	switch state.Status {
+	case velero.NamespaceNew:
+		return "new"
+	case velero.NamespaceEmpty:
+		return "empty"
+	case velero.NamespaceTerminating:
+		return "terminating"
+	}
+	return fmt.Sprintf("not empty (%d objects)", state.Objects)
+}
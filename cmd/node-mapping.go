@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	prompt "vresq/pkg/prompt"
+	velero "vresq/pkg/velero"
+)
+
+// unmappedNodeChoice leaves a missing node unmapped, Velero then removes it from the PersistentVolumeClaims provisioned on it.
+const unmappedNodeChoice = "Do not map it, let the scheduler select a node"
+
+// buildNodeMapping maps the nodes the restored PersistentVolumeClaims were provisioned on to nodes of the destination cluster.
+// The nodes which exist in the destination cluster are kept, the other ones without a --node-mapping are prompted for in interactive mode.
+func buildNodeMapping() (map[string]string, error) {
+	volumes, err := backupVolumes()
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes.SelectedNodes) == 0 && len(config.NodeMapping) == 0 {
+		return map[string]string{}, nil
+	}
+	if len(volumes.SelectedNodes) > 0 {
+		log.Printf("Nodes selected by the PersistentVolumeClaims of the backup: %s", strings.Join(volumes.SelectedNodes, ", "))
+	}
+	destinationNodes, err := velero.ListNodes(&destinationDynamiClient)
+	if err != nil {
+		return nil, fmt.Errorf("could not list nodes in the destination cluster: %v", err)
+	}
+
+	destinationNames := map[string]bool{}
+	for _, node := range destinationNodes {
+		destinationNames[node.Name] = true
+	}
+	mapping := map[string]string{}
+	for source, target := range config.NodeMapping {
+		mapping[source] = target
+	}
+	var sourceNodes []velero.Node
+	for _, name := range volumes.SelectedNodes {
+		if _, mapped := mapping[name]; mapped {
+			continue
+		}
+		if destinationNames[name] {
+			log.Printf("Node %s exists in the destination cluster, it is not mapped", name)
+			continue
+		}
+		if config.NonInteractive || len(destinationNodes) == 0 {
+			log.Printf("Warning: node %s does not exist in the destination cluster, Velero removes it from the PersistentVolumeClaims provisioned on it", name)
+			continue
+		}
+		// The source nodes are only listed to propose the destination nodes of the same zone first
+		if sourceNodes == nil {
+			if sourceNodes, err = velero.ListNodes(&sourceDynamiClient); err != nil {
+				log.Printf("Warning: could not list nodes in the source cluster: %v", err)
+				sourceNodes = []velero.Node{}
+			}
+		}
+		source := velero.Node{Name: name}
+		for _, node := range sourceNodes {
+			if node.Name == name {
+				source = node
+			}
+		}
+		if target := chooseNode(source, destinationNodes); target != "" {
+			mapping[name] = target
+		}
+	}
+
+	if err := velero.ValidateNodeMapping(mapping, destinationNodes); err != nil {
+		return nil, err
+	}
+	for _, source := range sortedKeys(mapping) {
+		log.Printf("Node %s mapped to %s", source, mapping[source])
+	}
+	return mapping, nil
+}
+
+// chooseNode prompts for the destination node of a source node, the nodes of the same zone being proposed first.
+// It returns an empty string when the node is left unmapped.
+func chooseNode(sourceNode velero.Node, destinationNodes []velero.Node) string {
+	nodes := append([]velero.Node{}, destinationNodes...)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return sourceNode.Zone != "" && nodes[i].Zone == sourceNode.Zone && nodes[j].Zone != sourceNode.Zone
+	})
+	choices := []string{}
+	byChoice := map[string]string{}
+	for _, node := range nodes {
+		choice := fmt.Sprintf("%s (%s)", node.Name, describeNode(node))
+		byChoice[choice] = node.Name
+		choices = append(choices, choice)
+	}
+	choices = append(choices, unmappedNodeChoice)
+	label := fmt.Sprintf("Destination node for the PersistentVolumeClaims provisioned on node '%s'", sourceNode.Name)
+	if sourceNode.Zone != "" {
+		label = fmt.Sprintf("%s (zone %s)", label, sourceNode.Zone)
+	}
+	return byChoice[prompt.SelectChoice(label, choices)]
+}
+
+// describeNode describes the zone and the roles of a node.
+func describeNode(node velero.Node) string {
+	zone := node.Zone
+	if zone == "" {
+		zone = "unknown"
+	}
+	roles := "none"
+	if len(node.Roles) > 0 {
+		roles = strings.Join(node.Roles, ", ")
+	}
+	return fmt.Sprintf("zone %s, roles %s", zone, roles)
+}
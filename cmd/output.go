@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormat string
+
+// addOutputFlag registers the --output flag on commands that print resources and validates it before the command runs.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&outputFormat, "output", outputTable, "Output format, one of: table, json, yaml")
+	cmd.RegisterFlagCompletionFunc("output", completeOutputFormats)
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat(outputFormat)
+	}
+}
+
+// validateOutputFormat checks that the given output format is supported.
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q, should be one of: table, json, yaml", format)
+	}
+}
+
+// printOutput writes data to w in the requested format. The table format is delegated to printTable.
+func printOutput(w io.Writer, format string, data interface{}, printTable func(tw *tabwriter.Writer)) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case outputYAML:
+		yamlBytes, err := yaml.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(yamlBytes)
+		return err
+	default:
+		if err := validateOutputFormat(format); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		printTable(tw)
+		return tw.Flush()
+	}
+}
+
+// joinOrNone joins the values with a comma, or returns "<none>" when there are none.
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "<none>"
+	}
+	return strings.Join(values, ",")
+}
+
+// valueOrNone returns the value, or "<none>" when it is empty.
+func valueOrNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+// sortedKeys returns the keys of the map in alphabetical order.
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Print the objects a restore would write to the destination cluster without applying them",
+	Long: `The "plan" command runs the whole restore workflow in dry-run mode: it is equivalent to "vresq --dry-run".
+The read-only BackupStorageLocation, the VolumeSnapshotLocations, their credentials Secrets (with redacted data), the ConfigMaps of the Velero plugins,
+the Restore and the Velero Helm values that would be written to the destination cluster are printed as YAML on the standard output,
+while logs are written to the standard error.
+
+Example usage:
+  $ vresq plan --source-context=<source-context> --destination-context=<destination-context> --backup-name=<backup-name> > plan.yaml
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config.DryRun = true
+		runRestore(cmd, args)
+	},
+}
+
+func init() {
+	addRunSummaryFlags(planCmd)
+	addExportFlag(planCmd)
+	rootCmd.AddCommand(planCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+	prompt "vresq/pkg/prompt"
+	velero "vresq/pkg/velero"
+)
+
+// backupContentsTimeout bounds the download of the backup contents, which hold every object of the backup.
+const backupContentsTimeout = 10 * time.Minute
+
+// restoredVolumes caches the volumes of the backup, which are read once for the storage class and the node mappings.
+var restoredVolumes *velero.BackupVolumes
+
+// backupVolumes describes the volumes restored from the backup, read from the backup contents.
+// When the backup contents cannot be downloaded, the PersistentVolumeClaims of the included namespaces in the source cluster are used instead.
+func backupVolumes() (velero.BackupVolumes, error) {
+	if restoredVolumes != nil {
+		return *restoredVolumes, nil
+	}
+	options := downloadOptions()
+	options.Timeout = backupContentsTimeout
+	volumes, err := velero.GetBackupVolumes(&sourceDynamiClient, config.SourceVeleroNamespace, config.VeleroRestoreOptions.BackupName,
+		config.VeleroRestoreOptions.IncludedNamespaces, config.VeleroRestoreOptions.ExcludedNamespaces, options)
+	if err != nil {
+		log.Printf("Warning: could not read the volumes from the contents of backup %s, using the PersistentVolumeClaims of the source cluster instead: %v",
+			config.VeleroRestoreOptions.BackupName, err)
+		volumes, err = velero.ListPersistentVolumeClaimVolumes(&sourceDynamiClient, config.VeleroRestoreOptions.IncludedNamespaces, config.VeleroRestoreOptions.ExcludedNamespaces)
+		if err != nil {
+			return velero.BackupVolumes{}, fmt.Errorf("could not list PersistentVolumeClaims in the source cluster: %v", err)
+		}
+	}
+	restoredVolumes = &volumes
+	return volumes, nil
+}
+
+// setupPluginConfigMaps writes the storage class and node mappings and the image name rules in the ConfigMaps of the Velero plugins
+// of the destination cluster, and returns the changes to revert once the restore is done.
+func setupPluginConfigMaps(storageClassMapping map[string]string, nodeMapping map[string]string) ([]velero.PluginConfigMapChange, error) {
+	configMaps := []struct {
+		plugin  string
+		name    string
+		mapping map[string]string
+	}{
+		{velero.ChangeStorageClassPlugin, config.StorageClassConfigMap, storageClassMapping},
+		{velero.ChangePVCNodeSelectorPlugin, config.NodeSelectorConfigMap, nodeMapping},
+		{velero.ChangeImageNamePlugin, config.ImageNameConfigMap, velero.ImageNameRulesData(config.ImageNameRules)},
+	}
+	changes := []velero.PluginConfigMapChange{}
+	for _, configMap := range configMaps {
+		change, err := velero.SetupPluginConfigMap(&destinationDynamiClient, config.DestinationVeleroNamespace, configMap.name, configMap.plugin, configMap.mapping)
+		if err != nil {
+			return changes, fmt.Errorf("could not set up the ConfigMap of the Velero %s plugin: %v", configMap.plugin, err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// revertPluginConfigMaps reverts the mappings written in the ConfigMaps of the Velero plugins for the restore, unless
+// --keep-storage-class-mapping, --keep-node-mapping or --keep-image-name-rules is given or the revert is declined when prompted.
+func revertPluginConfigMaps(changes []velero.PluginConfigMapChange) {
+	keep := map[string]bool{
+		velero.ChangeStorageClassPlugin:    config.KeepStorageClassMapping,
+		velero.ChangePVCNodeSelectorPlugin: config.KeepNodeMapping,
+		velero.ChangeImageNamePlugin:       config.KeepImageNameRules,
+	}
+	for _, change := range changes {
+		if len(change.Keys) == 0 {
+			continue
+		}
+		if keep[change.Plugin] {
+			log.Printf("Mappings of the Velero %s plugin kept in ConfigMap %s", change.Plugin, change.Name)
+			continue
+		}
+		label := fmt.Sprintf("Do you want to revert the mappings of the Velero %s plugin written in ConfigMap %s for the restore", change.Plugin, change.Name)
+		if !config.NonInteractive && !config.AssumeYes && !prompt.ConfirmUserChoice(label) {
+			log.Printf("Mappings of the Velero %s plugin kept in ConfigMap %s", change.Plugin, change.Name)
+			continue
+		}
+		// The restore is done, a failed revert is reported without failing the run
+		if err := velero.RevertPluginConfigMap(&destinationDynamiClient, change); err != nil {
+			log.Printf("Error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	profilesKey = "profiles"
+	// profileInheritsKey names the base profile a profile inherits its values from.
+	profileInheritsKey = "inherits"
+)
+
+// applyProfile merges the values of the named profile of the config file over its top-level values.
+// Flags and environment variables still take precedence over the profile values.
+func applyProfile(v *viper.Viper, name string) error {
+	values, err := resolveProfile(v, name, []string{})
+	if err != nil {
+		return err
+	}
+	return v.MergeConfigMap(values)
+}
+
+// resolveProfile returns the values of the named profile, merged over the values of the profiles it inherits from.
+// chain holds the profiles being resolved, to detect inheritance cycles.
+func resolveProfile(v *viper.Viper, name string, chain []string) (map[string]interface{}, error) {
+	// Viper lowercases the keys of the config file, profile names included
+	name = strings.ToLower(name)
+	for _, parent := range chain {
+		if parent == name {
+			return nil, fmt.Errorf("profile inheritance cycle: %s -> %s", strings.Join(chain, " -> "), name)
+		}
+	}
+	profiles := toStringMap(v.Get(profilesKey))
+	profile, found := profiles[name]
+	if !found {
+		return nil, fmt.Errorf("profile %q not found in the config file, available profiles: %s", name, joinOrNone(sortedMapKeys(profiles)))
+	}
+	values := toStringMap(profile)
+	if values == nil {
+		return nil, fmt.Errorf("profile %q should be a map of configuration values", name)
+	}
+
+	base, found := values[profileInheritsKey]
+	if !found {
+		return withoutInheritsKey(values), nil
+	}
+	baseName, ok := base.(string)
+	if !ok || baseName == "" {
+		return nil, fmt.Errorf("profile %q should inherit from a profile name", name)
+	}
+	baseValues, err := resolveProfile(v, baseName, append(chain, name))
+	if err != nil {
+		return nil, err
+	}
+	return mergeProfileValues(baseValues, withoutInheritsKey(values)), nil
+}
+
+// mergeProfileValues merges the values of a profile over the values of its base profile.
+// Maps, like the namespace mapping, are merged key by key, other values are replaced.
+func mergeProfileValues(base map[string]interface{}, values map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range values {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		valueMap := toStringMap(value)
+		if baseIsMap && valueMap != nil {
+			merged[key] = mergeProfileValues(baseMap, valueMap)
+			continue
+		}
+		if valueMap != nil {
+			value = valueMap
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// withoutInheritsKey returns a copy of the profile values without the inheritance key, which is not a configuration value.
+func withoutInheritsKey(values map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, value := range values {
+		if key != profileInheritsKey {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// toStringMap converts a map decoded from the config file to a map with string keys, or returns nil if it is not a map.
+func toStringMap(value interface{}) map[string]interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return typed
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for key, value := range typed {
+			result[fmt.Sprintf("%v", key)] = value
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// sortedMapKeys returns the keys of a map decoded from the config file in alphabetical order.
+func sortedMapKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// completeProfiles suggests the profiles of the config file.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	v, err := readConfigFile()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return sortedMapKeys(toStringMap(v.Get(profilesKey))), cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,383 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	kube "vresq/pkg/kubernetes"
+	prompt "vresq/pkg/prompt"
+	velero "vresq/pkg/velero"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	confirmDelete    bool
+	confirmUndo      bool
+	deleteNamespaces bool
+)
+
+var restoresCmd = &cobra.Command{
+	Use:     "restores",
+	Aliases: []string{"restore"},
+	Short:   "Manage Velero restores in the destination cluster",
+	Long: `The "restores" command lists, describes, deletes, watches and undoes the Velero restores of the destination cluster.
+It allows to keep track of a restore after the vresq run that created it was interrupted.
+
+Example usage:
+  $ vresq restores list --destination-context=<destination-context>
+  $ vresq restores describe <restore-name>
+  $ vresq restores watch <restore-name>
+  $ vresq restores results <restore-name>
+  $ vresq restores logs <restore-name> --restore-logs-dir=./logs
+  $ vresq restores delete <restore-name> --confirm
+  $ vresq restores undo <restore-name> --delete-namespaces
+`,
+}
+
+var restoresListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List Velero restores in the destination cluster",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverDestinationVeleroNamespace(); err != nil {
+			return err
+		}
+		restores, err := velero.ListRestores(&destinationDynamiClient, config.DestinationVeleroNamespace)
+		if err != nil {
+			return fmt.Errorf("could not list restores in namespace %s: %v", config.DestinationVeleroNamespace, err)
+		}
+
+		// Show the most recent restores first
+		sort.SliceStable(restores.Items, func(i, j int) bool {
+			return restores.Items[i].GetCreationTimestamp().After(restores.Items[j].GetCreationTimestamp().Time)
+		})
+
+		summaries := []velero.RestoreSummary{}
+		for _, restore := range restores.Items {
+			summaries = append(summaries, velero.GetRestoreSummary(restore))
+		}
+
+		return printOutput(os.Stdout, outputFormat, summaries, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "NAME\tBACKUP\tPHASE\tSTARTED\tCOMPLETED\tERRORS\tWARNINGS")
+			for _, summary := range summaries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\n",
+					summary.Name,
+					valueOrNone(summary.BackupName),
+					valueOrNone(summary.Phase),
+					valueOrNone(summary.StartTimestamp),
+					valueOrNone(summary.CompletionTimestamp),
+					summary.Errors,
+					summary.Warnings,
+				)
+			}
+		})
+	},
+}
+
+var restoresDescribeCmd = &cobra.Command{
+	Use:   "describe <restore-name>",
+	Short: "Describe a Velero restore in the destination cluster",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverDestinationVeleroNamespace(); err != nil {
+			return err
+		}
+		restore, err := velero.GetRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, args[0])
+		if err != nil {
+			return fmt.Errorf("could not get restore %s: %v", args[0], err)
+		}
+		details := velero.GetRestoreDetails(restore)
+
+		return printOutput(os.Stdout, outputFormat, details, func(tw *tabwriter.Writer) {
+			fmt.Fprintf(tw, "Name:\t%s\n", details.Name)
+			fmt.Fprintf(tw, "Namespace:\t%s\n", config.DestinationVeleroNamespace)
+			fmt.Fprintf(tw, "Backup:\t%s\n", valueOrNone(details.BackupName))
+			fmt.Fprintf(tw, "Schedule:\t%s\n", valueOrNone(details.ScheduleName))
+			fmt.Fprintf(tw, "Phase:\t%s\n", valueOrNone(details.Phase))
+			fmt.Fprintf(tw, "Progress:\t%d of %d items restored\n", details.ItemsRestored, details.TotalItems)
+			fmt.Fprintf(tw, "Errors:\t%d\n", details.Errors)
+			fmt.Fprintf(tw, "Warnings:\t%d\n", details.Warnings)
+			fmt.Fprintf(tw, "Validation Errors:\t%s\n", joinOrNone(details.ValidationErrors))
+			fmt.Fprintf(tw, "Hooks:\t%d attempted, %d failed\n", details.HookStatus.HooksAttempted, details.HookStatus.HooksFailed)
+			fmt.Fprintf(tw, "Started:\t%s\n", valueOrNone(details.StartTimestamp))
+			fmt.Fprintf(tw, "Completed:\t%s\n", valueOrNone(details.CompletionTimestamp))
+			for _, source := range sortedKeys(details.NamespaceMapping) {
+				fmt.Fprintf(tw, "Namespace Mapping:\t%s ==> %s\n", source, details.NamespaceMapping[source])
+			}
+		})
+	},
+}
+
+var restoresDeleteCmd = &cobra.Command{
+	Use:   "delete <restore-name>",
+	Short: "Delete a Velero restore in the destination cluster",
+	Long: `Delete a Velero restore in the destination cluster.
+Only the Restore object is deleted, the resources it restored are left untouched.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverDestinationVeleroNamespace(); err != nil {
+			return err
+		}
+		confirmed := confirmDelete || config.AssumeYes
+		if !confirmed && config.NonInteractive {
+			return fmt.Errorf("deleting restore %s requires --confirm in non-interactive mode", args[0])
+		}
+		if !confirmed && !prompt.ConfirmUserChoice(fmt.Sprintf("Do you confirm deleting restore %s in namespace %s", args[0], config.DestinationVeleroNamespace)) {
+			log.Println("Restore deletion cancelled")
+			return nil
+		}
+		if err := velero.DeleteRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, args[0]); err != nil {
+			return fmt.Errorf("could not delete restore %s: %v", args[0], err)
+		}
+		log.Printf("Restore %s deleted successfully", args[0])
+		return nil
+	},
+}
+
+var restoresUndoCmd = &cobra.Command{
+	Use:   "undo <restore-name>",
+	Short: "Delete the objects restored by a Velero restore in the destination cluster",
+	Long: `Delete the objects restored by a Velero restore in the destination cluster.
+Velero labels every object it restores with "velero.io/restore-name=<restore-name>". The API resources of the destination
+cluster are discovered to find every namespaced and cluster-scoped object carrying that label, even when the Restore object
+itself was deleted. Objects are deleted in a dependency-safe order: workloads first, then their configuration, volume claims,
+persistent volumes, cluster-scoped objects and custom resource definitions.
+
+When the Restore object still exists, labelled objects created before the restore started are left untouched.
+The namespaces Velero created for the restore are kept. With --delete-namespaces, the targets of the namespace mapping of
+the Restore created by the restore are also deleted; this requires the Restore object.
+Use --dry-run to only list the objects.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverDestinationVeleroNamespace(); err != nil {
+			return err
+		}
+		discoveryClient := kube.GetDiscoveryClientWithContext(config.DestinationKubeconfig, config.DestinationContext)
+
+		// The Restore tells which objects it created, the labelled objects are still listed when it was deleted
+		var restore *unstructured.Unstructured
+		restoreObject, err := velero.GetRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, args[0])
+		if err == nil {
+			restore = &restoreObject
+		} else if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("could not get restore %s: %v", args[0], err)
+		} else if deleteNamespaces {
+			log.Printf("Warning: restore %s was not found, the namespaces it created cannot be told apart and are kept", args[0])
+		}
+
+		objects, err := velero.ListRestoredObjects(&destinationDynamiClient, discoveryClient, args[0], restore, deleteNamespaces)
+		if err != nil {
+			return err
+		}
+		if len(objects) == 0 {
+			log.Printf("No object restored by restore %s found", args[0])
+			return nil
+		}
+		err = printOutput(os.Stdout, outputFormat, objects, func(tw *tabwriter.Writer) {
+			fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tACTION")
+			for _, object := range objects {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", object.Kind, object.Namespace, object.Name, object.Action)
+			}
+		})
+		if err != nil || config.DryRun {
+			return err
+		}
+
+		toDelete := []velero.RestoredObject{}
+		for _, object := range objects {
+			if object.Action == velero.UndoActionDelete {
+				toDelete = append(toDelete, object)
+			}
+		}
+		if len(toDelete) == 0 {
+			log.Println("Nothing to delete")
+			return nil
+		}
+		confirmed := confirmUndo || config.AssumeYes
+		if !confirmed && config.NonInteractive {
+			return fmt.Errorf("undoing restore %s requires --confirm in non-interactive mode", args[0])
+		}
+		if !confirmed && !prompt.ConfirmUserChoice(fmt.Sprintf("Do you confirm deleting these %d objects restored by restore %s", len(toDelete), args[0])) {
+			log.Println("Restore undo cancelled")
+			return nil
+		}
+
+		// Keep going on errors so that one stuck object does not prevent deleting the others
+		var errs []error
+		for _, object := range toDelete {
+			if err := velero.DeleteRestoredObject(&destinationDynamiClient, object); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			log.Printf("%s %s/%s deleted", object.Kind, object.Namespace, object.Name)
+		}
+		return errors.Join(errs...)
+	},
+}
+
+var restoresWatchCmd = &cobra.Command{
+	Use:   "watch <restore-name>",
+	Short: "Watch a Velero restore in the destination cluster until it completes or fails",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverDestinationVeleroNamespace(); err != nil {
+			return err
+		}
+		err := velero.WatchRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, args[0])
+		reportRestoreFailure(err)
+		return err
+	},
+}
+
+var restoresResultsCmd = &cobra.Command{
+	Use:   "results <restore-name>",
+	Short: "Show the warnings and errors of a Velero restore in the destination cluster",
+	Long: `Show the warnings and errors of a Velero restore in the destination cluster.
+They are fetched from the object storage through a Velero DownloadRequest.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverDestinationVeleroNamespace(); err != nil {
+			return err
+		}
+		results, err := velero.GetRestoreResults(&destinationDynamiClient, config.DestinationVeleroNamespace, args[0], downloadOptions())
+		if err != nil {
+			return err
+		}
+		if outputFormat == outputTable {
+			return velero.WriteRestoreResults(os.Stdout, results)
+		}
+		return printOutput(os.Stdout, outputFormat, results, nil)
+	},
+}
+
+var restoresLogsCmd = &cobra.Command{
+	Use:   "logs <restore-name>",
+	Short: "Show the logs of a Velero restore in the destination cluster",
+	Long: `Show the logs of a Velero restore in the destination cluster.
+They are fetched from the object storage through a Velero DownloadRequest.
+When --restore-logs-dir is given, the logs are saved in that directory instead of being printed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setupKubernetesClients()
+		if err := discoverDestinationVeleroNamespace(); err != nil {
+			return err
+		}
+		if config.RestoreLogsDir != "" {
+			return saveRestoreLogs(args[0])
+		}
+		reader, err := velero.Download(&destinationDynamiClient, config.DestinationVeleroNamespace, velero.DownloadTargetKindRestoreLog, args[0], downloadOptions())
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		_, err = io.Copy(os.Stdout, reader)
+		return err
+	},
+}
+
+// downloadOptions returns the options used to fetch restore logs and results from the object storage.
+func downloadOptions() velero.DownloadOptions {
+	return velero.DownloadOptions{
+		InsecureSkipTLSVerify: config.InsecureSkipTLSVerify,
+		CACertFile:            config.CACertFile,
+	}
+}
+
+// reportRestoreFailure prints the warnings and errors of a failed restore, and saves its logs when a logs directory is configured.
+// It does nothing if err does not indicate a failed restore.
+func reportRestoreFailure(err error) {
+	var restoreFailedError velero.RestoreFailedError
+	if !errors.As(err, &restoreFailedError) {
+		return
+	}
+
+	// No logs nor results are uploaded for a restore that did not pass validation
+	if restoreFailedError.Phase == "FailedValidation" {
+		restore, err := velero.GetRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, restoreFailedError.Name)
+		if err != nil {
+			log.Printf("Warning: could not get restore %s: %v", restoreFailedError.Name, err)
+			return
+		}
+		for _, validationError := range velero.GetRestoreDetails(restore).ValidationErrors {
+			log.Printf("Validation error: %s", validationError)
+		}
+		return
+	}
+
+	log.Printf("Fetching results of restore %s ...", restoreFailedError.Name)
+	results, err := velero.GetRestoreResults(&destinationDynamiClient, config.DestinationVeleroNamespace, restoreFailedError.Name, downloadOptions())
+	if err != nil {
+		log.Printf("Warning: could not fetch results of restore %s: %v", restoreFailedError.Name, err)
+	} else if err := velero.WriteRestoreResults(os.Stderr, results); err != nil {
+		log.Printf("Warning: could not print results of restore %s: %v", restoreFailedError.Name, err)
+	}
+
+	if config.RestoreLogsDir != "" {
+		if err := saveRestoreLogs(restoreFailedError.Name); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// reportRestoreHooks logs the execution status of the hooks of a restore.
+// Velero only counts the attempted and failed hooks, the failures themselves are in the restore results and logs.
+func reportRestoreHooks(restoreName string) {
+	restore, err := velero.GetRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, restoreName)
+	if err != nil {
+		log.Printf("Warning: could not get the hooks status of restore %s: %v", restoreName, err)
+		return
+	}
+	status := velero.GetRestoreHookStatus(restore)
+	log.Printf("Restore hooks: %d attempted, %d failed", status.HooksAttempted, status.HooksFailed)
+	if status.HooksFailed > 0 {
+		log.Printf("Warning: %d restore hooks failed, run \"vresq restores results %s\" and \"vresq restores logs %s\" for details", status.HooksFailed, restoreName, restoreName)
+	}
+}
+
+// saveRestoreLogs saves the logs of a restore in the configured logs directory.
+func saveRestoreLogs(restoreName string) error {
+	if err := os.MkdirAll(config.RestoreLogsDir, 0o755); err != nil {
+		return fmt.Errorf("could not create restore logs directory %s: %v", config.RestoreLogsDir, err)
+	}
+	path := filepath.Join(config.RestoreLogsDir, fmt.Sprintf("%s-logs.txt", restoreName))
+	if err := velero.SaveRestoreLogs(&destinationDynamiClient, config.DestinationVeleroNamespace, restoreName, downloadOptions(), path); err != nil {
+		return fmt.Errorf("could not save logs of restore %s: %v", restoreName, err)
+	}
+	log.Printf("Logs of restore %s saved in %s", restoreName, path)
+	return nil
+}
+
+func init() {
+	addOutputFlag(restoresListCmd)
+	addOutputFlag(restoresDescribeCmd)
+	addOutputFlag(restoresResultsCmd)
+	addOutputFlag(restoresUndoCmd)
+	for _, restoreCmd := range []*cobra.Command{restoresDescribeCmd, restoresDeleteCmd, restoresUndoCmd, restoresWatchCmd, restoresResultsCmd, restoresLogsCmd} {
+		restoreCmd.ValidArgsFunction = completeRestoreNames
+	}
+	restoresDeleteCmd.Flags().BoolVar(&confirmDelete, "confirm", false, "Delete the restore without asking for confirmation")
+	restoresUndoCmd.Flags().BoolVar(&confirmUndo, "confirm", false, "Delete the restored objects without asking for confirmation")
+	restoresUndoCmd.Flags().BoolVar(&deleteNamespaces, "delete-namespaces", false, "Also delete the targets of the namespace mapping created by the restore")
+	restoresCmd.AddCommand(restoresListCmd)
+	restoresCmd.AddCommand(restoresDescribeCmd)
+	restoresCmd.AddCommand(restoresDeleteCmd)
+	restoresCmd.AddCommand(restoresUndoCmd)
+	restoresCmd.AddCommand(restoresWatchCmd)
+	restoresCmd.AddCommand(restoresResultsCmd)
+	restoresCmd.AddCommand(restoresLogsCmd)
+	rootCmd.AddCommand(restoresCmd)
+}
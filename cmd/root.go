@@ -7,11 +7,12 @@ $ vresq --source-kubeconfig=<source-path> --source-context=<source-context> --de
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"regexp"
 	"strings"
+	"time"
 	common "vresq/pkg/common"
 	kube "vresq/pkg/kubernetes"
 	prompt "vresq/pkg/prompt"
@@ -36,7 +37,8 @@ var (
 )
 
 const (
-	defaultConfigFilename = "vresq"
+	defaultConfigFilename  = "vresq"
+	defaultVeleroNamespace = "velero"
 	// The environment variable prefix of all environment variables bound to our command line flags.
 	// For example, --number is bound to VRESQ_NUMBER.
 	envPrefix                  = "VRESQ"
@@ -65,163 +67,310 @@ OR run using arguments:
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		return initConfig(cmd)
 	},
-	Run: func(cmd *cobra.Command, args []string) {
-		// Check if source kubeconfig is provided, if not, prompt user to choose from default kubeconfig
-		if config.SourceKubeconfig == "" {
-			var err error
-			log.Printf("No source kubeconfig given, parsing contexts in default kubeconfig in %s ...", defaultSourceKubeconfig)
-			config.SourceKubeconfig = defaultSourceKubeconfig
+	Run: runRestore,
+}
+
+// runRestore runs the restore workflow, prompting for every missing value.
+// In non-interactive mode, missing values are reported as one error instead of being prompted.
+// In dry-run mode, the objects that would be written to the destination cluster are printed instead of being applied.
+// When requested, a run summary is written at the end of the run, with or without a restore error.
+func runRestore(cmd *cobra.Command, args []string) {
+	startedAt := time.Now()
+	veleroCloned := false
+
+	// Every object created or modified during this run is marked with its ID, to be able to clean it up later
+	velero.SetRunID(velero.NewRunID())
+	log.Printf("Run ID: %s", velero.GetRunID())
+
+	if config.DryRun {
+		// The standard output is kept for the run summary when it is printed there
+		if runSummaryToStdout() {
+			log.Println("Dry run: nothing will be applied, the objects that would be written to the destination cluster are printed below")
+			velero.SetDryRun(os.Stderr)
+		} else {
+			log.Println("Dry run: nothing will be applied, the objects that would be written to the destination cluster are printed on the standard output")
+			velero.SetDryRun(os.Stdout)
+		}
+	}
+
+	// In non-interactive mode, fail early with every missing value at once
+	if config.NonInteractive {
+		if err := checkNonInteractiveConfig(); err != nil {
+			log.Fatalf("Error: missing required values in non-interactive mode:\n%v", err)
+		}
+	}
+
+	// Invalid selectors and hooks are only rejected by Velero once the restore is created, after Velero was set up in the destination cluster
+	if err := velero.ValidateLabelSelectors(config.VeleroRestoreOptions); err != nil {
+		log.Fatalf("Error: invalid label selectors:\n%v", err)
+	}
+	if err := velero.ValidateRestoreHooks(config.VeleroRestoreOptions.RestoreHooks); err != nil {
+		log.Fatalf("Error: invalid restore hooks:\n%v", err)
+	}
+	if err := velero.ValidateNamespaceConflictPolicy(config.OnNamespaceConflict); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := velero.ValidateImageNameRules(config.ImageNameRules); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	namespaceMappingRules, err := velero.ParseNamespaceMappingRules(config.NamespaceMappingRules)
+	if err != nil {
+		log.Fatalf("Error: invalid namespace mapping rules:\n%v", err)
+	}
+	var resourceModifiers velero.ResourceModifiers
+	if velero.HasResourceModifiers(config.VeleroRestoreOptions) {
+		resourceModifiers, err = velero.LoadResourceModifiers(config.VeleroRestoreOptions)
+		if err != nil {
+			log.Fatalf("Error: invalid resource modifiers:\n%v", err)
+		}
+	}
+
+	// Check if source kubeconfig is provided, if not, prompt user to choose from default kubeconfig
+	if config.SourceKubeconfig == "" {
+		var err error
+		log.Printf("No source kubeconfig given, parsing contexts in default kubeconfig in %s ...", defaultSourceKubeconfig)
+		config.SourceKubeconfig = defaultSourceKubeconfig
+		if !config.NonInteractive {
 			config.SourceContext, err = prompt.ChooseKubeconfigContext(defaultSourceKubeconfig, "Source", &config)
 			if err != nil {
 				log.Fatalf("Error selecting source context: %v", err)
 			}
 		}
-		// Check if destination kubeconfig is provided, if not, prompt user to choose or use source kubeconfig
-		if config.DestinationKubeconfig == "" {
-			label := "No destination kubeconfig given, do you want to use the source kubeconfig as a destination "
-			selected := prompt.ConfirmUserChoice(label)
-			var defaultDestinationKubeconfig string
-			var err error
-			if selected {
-				defaultDestinationKubeconfig = config.SourceKubeconfig
-			} else {
-				defaultDestinationKubeconfig, err = prompt.ChooseDestinationKubeconfig()
-				if err != nil {
-					log.Fatalf("%v", err)
-				}
-			}
-			config.DestinationKubeconfig = defaultDestinationKubeconfig
-			config.DestinationContext, err = prompt.ChooseKubeconfigContext(defaultDestinationKubeconfig, "Destination", &config)
+	}
+	// Check if destination kubeconfig is provided, if not, prompt user to choose or use source kubeconfig
+	if config.DestinationKubeconfig == "" && config.NonInteractive {
+		// checkNonInteractiveConfig guarantees the source kubeconfig should be used
+		log.Printf("No destination kubeconfig given, using the source kubeconfig as a destination")
+		config.DestinationKubeconfig = config.SourceKubeconfig
+		if config.DestinationContext == "" {
+			config.DestinationContext = config.SourceContext
+		}
+	}
+	if config.DestinationKubeconfig == "" {
+		label := "No destination kubeconfig given, do you want to use the source kubeconfig as a destination "
+		selected := config.UseSourceKubeconfig || prompt.ConfirmUserChoice(label)
+		var defaultDestinationKubeconfig string
+		var err error
+		if selected {
+			defaultDestinationKubeconfig = config.SourceKubeconfig
+		} else {
+			defaultDestinationKubeconfig, err = prompt.ChooseDestinationKubeconfig()
 			if err != nil {
-				log.Fatalf("Error selecting destination context: %v", err)
+				log.Fatalf("%v", err)
 			}
 		}
-
-		// Check current context and set up source and destination Kubernetes clients accordingly
-		currentContext := kube.CurrentContext{
-			SameOrOnlySourceKubeconfig: config.DestinationKubeconfig == "" || config.DestinationKubeconfig == config.SourceKubeconfig,
-			SameOrOnlySourceContext:    config.DestinationContext == "" || config.DestinationContext == config.SourceContext,
-			NoGivenContext:             config.DestinationContext == "" && config.SourceContext == "",
+		config.DestinationKubeconfig = defaultDestinationKubeconfig
+		config.DestinationContext, err = prompt.ChooseKubeconfigContext(defaultDestinationKubeconfig, "Destination", &config)
+		if err != nil {
+			log.Fatalf("Error selecting destination context: %v", err)
 		}
-		kube.SetupSourceAndDestinationKubernetesClients(&sourceDynamiClient, &destinationDynamiClient, &currentContext, &config)
+	}
 
-		// If source Velero namespace is not provided, try to detect it from the source cluster
-		if config.SourceVeleroNamespace == "" {
-			var err error
-			veleroPod, err := velero.GetVeleroPod(&sourceDynamiClient)
-			if err != nil {
-				log.Fatalf("Error: could not discover source velero namespace. Please specify one. %v", err)
-			}
-			config.SourceVeleroNamespace = veleroPod.GetNamespace()
-		}
+	// Check current context and set up source and destination Kubernetes clients accordingly
+	currentContext := newCurrentContext()
+	kube.SetupSourceAndDestinationKubernetesClients(&sourceDynamiClient, &destinationDynamiClient, &currentContext, &config)
 
-		// If destination Velero namespace is not provided, handle it
-		if config.DestinationVeleroNamespace == "" {
-			var err error
-			veleroPod, err := velero.GetVeleroPod(&destinationDynamiClient)
-			config.DestinationVeleroNamespace = veleroPod.GetNamespace()
-			if err != nil {
-				if _, ok := err.(velero.NotFoundError); ok {
-					log.Printf("Info: could not discover velero namespace in destination cluster.")
+	// If source Velero namespace is not provided, try to detect it from the source cluster
+	if err := discoverSourceVeleroNamespace(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// If destination Velero namespace is not provided, handle it
+	if config.DestinationVeleroNamespace == "" {
+		var err error
+		veleroPod, err := velero.GetVeleroPod(&destinationDynamiClient)
+		config.DestinationVeleroNamespace = veleroPod.GetNamespace()
+		if err != nil {
+			if _, ok := err.(velero.NotFoundError); ok {
+				log.Printf("Info: could not discover velero namespace in destination cluster.")
+				selected := ConfirmYes
+				if !config.CloneVelero {
+					if config.NonInteractive {
+						log.Fatalf("Error: no Velero server was discovered in destination cluster. Use --clone-velero to clone it from the source cluster, or --destination-velero-namespace to use an existing one.")
+					}
 					confirmChoices := []string{ConfirmYes, ConfirmNo}
 					promptSelect := promptui.Select{
 						Label: "No Velero helm chart was discovered in destination cluster, do you want to clone it from source cluster ?",
 						Items: confirmChoices,
 						Size:  2,
 					}
-					_, selected, err := promptSelect.Run()
+					_, selected, err = promptSelect.Run()
 					if err != nil {
 						log.Fatalf("Error: %v", err)
 					}
-					if !currentContext.SameOrOnlySourceKubeconfig || !currentContext.SameOrOnlySourceContext {
-						if config.DestinationVeleroNamespace == "" {
-							label := "Namespace for velero installation in destination cluster:"
-							validationError := "namespace name should match the regex: '[a-z0-9]([-a-z0-9]*[a-z0-9])?'"
-							regex := regexp.MustCompile(`[a-z0-9]([-a-z0-9]*[a-z0-9])?`)
-							chosenNamespace, err := prompt.UserInput(regex, validationError, label, "velero")
-							if err != nil {
-								log.Fatalf("Error: could not construct namespaces mapping, %v", err)
-							}
-							config.DestinationVeleroNamespace = chosenNamespace
-						}
-						if selected == ConfirmYes {
-							log.Println("Cloning Velero helm release from source cluster...")
-							kube.SetupSourceAndDestinationHelmClients(&sourceHelmClient, &destinationHelmClient, &currentContext, &config)
-							velero.SetupVelero(sourceHelmClient, &sourceDynamiClient, destinationHelmClient, &destinationDynamiClient, &config)
-						} else {
-							log.Println("Skipping Velero helm release Cloning from source cluster...")
+				}
+				if !currentContext.SameOrOnlySourceKubeconfig || !currentContext.SameOrOnlySourceContext {
+					if config.DestinationVeleroNamespace == "" && config.NonInteractive {
+						log.Printf("No destination velero namespace given, installing velero in namespace %s", defaultVeleroNamespace)
+						config.DestinationVeleroNamespace = defaultVeleroNamespace
+					}
+					if config.DestinationVeleroNamespace == "" {
+						label := "Namespace for velero installation in destination cluster:"
+						chosenNamespace, err := prompt.NamespaceInput(label, defaultVeleroNamespace)
+						if err != nil {
+							log.Fatalf("Error: could not construct namespaces mapping, %v", err)
 						}
+						config.DestinationVeleroNamespace = chosenNamespace
+					}
+					if selected == ConfirmYes {
+						log.Println("Cloning Velero helm release from source cluster...")
+						kube.SetupSourceAndDestinationHelmClients(&sourceHelmClient, &destinationHelmClient, &currentContext, &config)
+						velero.SetupVelero(sourceHelmClient, &sourceDynamiClient, destinationHelmClient, &destinationDynamiClient, &config)
+						veleroCloned = true
+					} else {
+						log.Println("Skipping Velero helm release Cloning from source cluster...")
 					}
-				} else {
-					log.Fatal(err)
 				}
+			} else {
+				log.Fatal(err)
 			}
 		}
+	}
 
-		// If restore name is not provided, prompt user to choose one
-		if config.RestoreName == "" {
-			restoreName, err := prompt.ChooseRestoreName()
-			if err != nil {
-				log.Fatalf("Could not get Restore name, %v", err)
-			}
-			config.RestoreName = restoreName
+	// If restore name is not provided, prompt user to choose one
+	if config.RestoreName == "" {
+		restoreName, err := prompt.ChooseRestoreName()
+		if err != nil {
+			log.Fatalf("Could not get Restore name, %v", err)
 		}
+		config.RestoreName = restoreName
+	}
 
-		// If Velero backup name is not provided, prompt user to choose one
-		if config.VeleroRestoreOptions.BackupName == "" {
-			selectedBackup, err := prompt.ChooseBackup(&sourceDynamiClient, config)
-			config.VeleroRestoreOptions.BackupName = selectedBackup
-			if err != nil {
-				log.Fatal(err)
-			}
+	// If only a schedule is provided, use its most recent successful backup
+	if config.VeleroRestoreOptions.BackupName == "" && config.VeleroRestoreOptions.ScheduleName != "" {
+		if err := resolveScheduleBackup(); err != nil {
+			log.Fatalf("Error: %v", err)
 		}
+	}
 
-		// If included namespaces are not provided, prompt user to choose them
-		if len(config.VeleroRestoreOptions.IncludedNamespaces) == 0 {
-			namespaces, err := prompt.ChooseNamespaces(&sourceDynamiClient, &config)
-			if err != nil {
-				log.Fatalf("Error: could not get namespaces that should be included in restore, %v", err)
-			}
-			config.VeleroRestoreOptions.IncludedNamespaces = namespaces
+	// If Velero backup name is not provided, prompt user to choose one
+	if config.VeleroRestoreOptions.BackupName == "" {
+		selectedBackup, err := prompt.ChooseBackup(&sourceDynamiClient, config)
+		config.VeleroRestoreOptions.BackupName = selectedBackup
+		if err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		// If namespace mapping is not provided, prompt user to choose them
-		if len(config.VeleroRestoreOptions.NamespaceMapping) == 0 {
-			var selected = false
-			for {
-				for _, namespace := range config.VeleroRestoreOptions.IncludedNamespaces {
-					label := fmt.Sprintf("Destination namespace for namespace '%s' restoration :", namespace)
-					validationError := "namespace name should match the regex: '[a-z0-9]([-a-z0-9]*[a-z0-9])?'"
-					regex := regexp.MustCompile(`[a-z0-9]([-a-z0-9]*[a-z0-9])?`)
-					chosenNamespace, err := prompt.UserInput(regex, validationError, label, fmt.Sprintf("%s-%s", config.RestoreName, namespace))
-					if err != nil {
-						log.Fatalf("Error: could not construct namespaces mapping, %v", err)
-					}
-					config.VeleroRestoreOptions.NamespaceMapping[namespace] = chosenNamespace
-				}
-				var labelParts []string
-				labelParts = append(labelParts, "Do you confirm the following choice : ")
-				for snamespace, dnamespace := range config.VeleroRestoreOptions.NamespaceMapping {
-					labelParts = append(labelParts, fmt.Sprintf("%s ==> %s", snamespace, dnamespace))
-				}
-				label := strings.Join(labelParts, ", ")
-				selected = prompt.ConfirmUserChoice(label)
-				if selected {
-					break
+	// If included namespaces are not provided, prompt user to choose them
+	if len(config.VeleroRestoreOptions.IncludedNamespaces) == 0 {
+		namespaces, err := prompt.ChooseNamespaces(&sourceDynamiClient, &config)
+		if err != nil {
+			log.Fatalf("Error: could not get namespaces that should be included in restore, %v", err)
+		}
+		config.VeleroRestoreOptions.IncludedNamespaces = namespaces
+	}
+
+	// Map the namespaces without an explicit mapping with the rules, if any
+	if len(namespaceMappingRules) > 0 {
+		if err := expandNamespaceMappingRules(namespaceMappingRules); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	// The prompt below cannot run without a terminal
+	if len(config.VeleroRestoreOptions.NamespaceMapping) == 0 && config.NonInteractive {
+		log.Fatalf("Error: the namespace mapping rules %s map none of the restored namespaces %s, set --namespace-mapping or fix --namespace-mapping-rule",
+			strings.Join(config.NamespaceMappingRules, ", "), strings.Join(config.VeleroRestoreOptions.IncludedNamespaces, ", "))
+	}
+
+	// If namespace mapping is not provided, prompt user to choose them
+	if len(config.VeleroRestoreOptions.NamespaceMapping) == 0 {
+		var selected = false
+		for {
+			for _, namespace := range config.VeleroRestoreOptions.IncludedNamespaces {
+				label := fmt.Sprintf("Destination namespace for namespace '%s' restoration :", namespace)
+				chosenNamespace, err := prompt.NamespaceInput(label, fmt.Sprintf("%s-%s", config.RestoreName, namespace))
+				if err != nil {
+					log.Fatalf("Error: could not construct namespaces mapping, %v", err)
 				}
+				config.VeleroRestoreOptions.NamespaceMapping[namespace] = chosenNamespace
+			}
+			var labelParts []string
+			labelParts = append(labelParts, "Do you confirm the following choice : ")
+			for snamespace, dnamespace := range config.VeleroRestoreOptions.NamespaceMapping {
+				labelParts = append(labelParts, fmt.Sprintf("%s ==> %s", snamespace, dnamespace))
+			}
+			label := strings.Join(labelParts, ", ")
+			selected = prompt.ConfirmUserChoice(label)
+			if selected {
+				break
 			}
 		}
+	}
+
+	if err := velero.ValidateNamespaceMapping(config.VeleroRestoreOptions.NamespaceMapping, config.VeleroRestoreOptions.IncludedNamespaces); err != nil {
+		log.Fatalf("Error: invalid namespace mapping:\n%v", err)
+	}
 
-		// Set up Velero backup location and configmap
-		velero.SetupVeleroBackupLocation(&sourceDynamiClient, &destinationDynamiClient, &config)
-		velero.SetupVeleroConfigmap(&sourceDynamiClient, &destinationDynamiClient, config.DestinationVeleroNamespace)
+	// Restoring in existing namespaces would merge into their live objects
+	if err := resolveNamespaceConflicts(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
-		// Create Velero restore
-		err := velero.CreateVeleroRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, config.RestoreName, config.VeleroRestoreOptions)
-		if err != nil {
-			log.Fatalf("Error creating Velero Restore: %v", err)
+	storageClassMapping, err := buildStorageClassMapping()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	nodeMapping, err := buildNodeMapping()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Set up Velero backup and volume snapshot locations and plugin configmaps
+	velero.SetupVeleroBackupLocation(&sourceDynamiClient, &destinationDynamiClient, &config)
+	if err := velero.SetupVolumeSnapshotLocations(&sourceDynamiClient, &destinationDynamiClient, &config); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	pluginConfigMapChanges, err := setupPluginConfigMaps(storageClassMapping, nodeMapping)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if velero.HasResourceModifiers(config.VeleroRestoreOptions) {
+		if err := velero.CreateResourceModifiersConfigMap(&destinationDynamiClient, config.DestinationVeleroNamespace, config.RestoreName, resourceModifiers); err != nil {
+			log.Fatalf("Error: %v", err)
 		}
-	},
+	}
+
+	// Export the restore before creating it, so that it can be replayed with velero even if vresq is interrupted
+	if exportDir != "" {
+		if err := exportRestore(resourceModifiers); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	// Create Velero restore
+	err = velero.CreateVeleroRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, config.RestoreName, config.VeleroRestoreOptions)
+	if err != nil {
+		reportRestoreFailure(err)
+	}
+	// The plugin mappings are only needed while Velero restores the objects
+	if !config.DryRun {
+		revertPluginConfigMaps(pluginConfigMapChanges)
+	}
+	if len(config.VeleroRestoreOptions.RestoreHooks) > 0 && !config.DryRun {
+		reportRestoreHooks(config.RestoreName)
+	}
+
+	// Velero reports a completed restore once the objects are created, verify that the application actually starts
+	var verification []velero.WorkloadStatus
+	var verifyErr error
+	if err == nil && config.Verify && !config.DryRun {
+		verification, verifyErr = verifyRestoredWorkloads()
+	}
+	if runSummaryRequested() {
+		if err := writeRunSummary(newRunSummary(startedAt, veleroCloned, verification, errors.Join(err, verifyErr))); err != nil {
+			log.Printf("Error: could not write run summary: %v", err)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Error creating Velero Restore: %v", err)
+	}
+	if verifyErr != nil {
+		log.Fatalf("Error verifying restored workloads: %v", verifyErr)
+	}
+	if config.DryRun {
+		log.Println("Dry run completed, nothing was applied to the destination cluster")
+	}
 }
 
 func Execute() {
@@ -242,18 +391,45 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&config.RestoreName, "restore-name", "o", viper.GetString("RESTORE_NAME"), "name for Velero Restore")
 	rootCmd.PersistentFlags().StringVarP(&config.VeleroRestoreOptions.BackupName, "backup-name", "b", viper.GetString("BACKUP_NAME"), "Velero backup name")
 	rootCmd.PersistentFlags().StringVarP(&config.VeleroRestoreOptions.ScheduleName, "schedule-name", "c", viper.GetString("SCHEDULE_NAME"), "Velero schedule name")
+	rootCmd.PersistentFlags().BoolVarP(&config.VeleroRestoreOptions.AllowPartiallyFailed, "allow-partially-failed", "", viper.GetBool("ALLOW_PARTIALLY_FAILED"), "Whether --schedule-name may resolve to a PartiallyFailed backup, not only to a Completed one")
 	rootCmd.PersistentFlags().DurationVarP(&config.VeleroRestoreOptions.ItemOperationTimeout, "item-operation-timeout", "t", viper.GetDuration("ITEM_OPERATION_TIMEOUT"), "Time used to wait for asynchronous BackupItemAction operations")
 	rootCmd.PersistentFlags().StringSliceVarP(&config.VeleroRestoreOptions.IncludedNamespaces, "included-namespaces", "i", viper.GetStringSlice("INCLUDED_NAMESPACES"), "Array of namespaces to include in the restore")
 	rootCmd.PersistentFlags().StringSliceVarP(&config.VeleroRestoreOptions.ExcludedNamespaces, "excluded-namespaces", "e", viper.GetStringSlice("EXCLUDED_NAMESPACES"), "Array of namespaces to exclude from the restore")
 	rootCmd.PersistentFlags().StringSliceVarP(&config.VeleroRestoreOptions.IncludedResources, "included-resources", "l", viper.GetStringSlice("INCLUDED_RESOURCES"), "Array of resources to include in the restore")
 	rootCmd.PersistentFlags().StringSliceVarP(&config.VeleroRestoreOptions.ExcludedResources, "excluded-resources", "x", viper.GetStringSlice("EXCLUDED_RESOURCES"), "Array of resources to exclude from the restore")
 	rootCmd.PersistentFlags().BoolVarP(&config.VeleroRestoreOptions.IncludeClusterResources, "include-cluster-resources", "C", viper.GetBool("INCLUDE_CLUSTER_RESOURCES"), "Whether or not to include cluster-scoped resources")
-	rootCmd.PersistentFlags().StringToStringVarP(&config.VeleroRestoreOptions.LabelSelector, "label-selector", "L", viper.GetStringMapString("LABEL_SELECTOR"), "Individual objects must match this label selector to be included in the restore")
-	rootCmd.PersistentFlags().StringToStringVarP(&config.VeleroRestoreOptions.OrLabelSelectors, "or-label-selectors", "O", viper.GetStringMapString("OR_LABEL_SELECTORS"), "Individual object when matched with any of the label selectors specified in the set are to be included in the restore")
+	rootCmd.PersistentFlags().StringVarP(&config.VeleroRestoreOptions.LabelSelector, "label-selector", "L", viper.GetString("LABEL_SELECTOR"), "Individual objects must match this label selector to be included in the restore, like \"app=shop,env in (prod,staging),!canary\"")
+	rootCmd.PersistentFlags().StringArrayVarP(&config.VeleroRestoreOptions.OrLabelSelectors, "or-label-selectors", "O", viper.GetStringSlice("OR_LABEL_SELECTORS"), "Individual objects matching any of these label selectors are included in the restore. Repeat the flag or separate the selectors with \" or \"")
 	rootCmd.PersistentFlags().StringToStringVarP(&config.VeleroRestoreOptions.NamespaceMapping, "namespace-mapping", "M", viper.GetStringMapString("NAMESPACE_MAPPING"), "Map of source namespace names to target namespace names to restore into")
+	rootCmd.PersistentFlags().StringToStringVarP(&config.StorageClassMapping, "storage-class-mapping", "", viper.GetStringMapString("STORAGE_CLASS_MAPPING"), "Map of source storage class names to destination storage class names. Unmapped storage classes are prompted for, or mapped to the default destination storage class in non-interactive mode")
+	rootCmd.PersistentFlags().StringVarP(&config.StorageClassConfigMap, "storage-class-configmap", "", viper.GetString("STORAGE_CLASS_CONFIGMAP"), "name of the ConfigMap of the Velero change storage class plugin in the destination Velero namespace, when none exists yet")
+	rootCmd.PersistentFlags().BoolVarP(&config.KeepStorageClassMapping, "keep-storage-class-mapping", "", viper.GetBool("KEEP_STORAGE_CLASS_MAPPING"), "Keep the storage class mappings in the ConfigMap after the restore instead of reverting them")
+	rootCmd.PersistentFlags().StringToStringVarP(&config.NodeMapping, "node-mapping", "", viper.GetStringMapString("NODE_MAPPING"), "Map of source node names to destination node names, for the PersistentVolumeClaims provisioned on a node like local volumes. Unmapped missing nodes are prompted for")
+	rootCmd.PersistentFlags().StringVarP(&config.NodeSelectorConfigMap, "node-selector-configmap", "", viper.GetString("NODE_SELECTOR_CONFIGMAP"), "name of the ConfigMap of the Velero change PVC node selector plugin in the destination Velero namespace, when none exists yet")
+	rootCmd.PersistentFlags().BoolVarP(&config.KeepNodeMapping, "keep-node-mapping", "", viper.GetBool("KEEP_NODE_MAPPING"), "Keep the node mappings in the ConfigMap after the restore instead of reverting them")
+	rootCmd.PersistentFlags().StringArrayVarP(&config.ImageNameRules, "image-name-rule", "", viper.GetStringSlice("IMAGE_NAME_RULE"), "Rule rewriting the images of the restored pods: <old>,<new> replaces <old> with <new> in the image names, like registry.example.com,registry.dr.example.com. Can be repeated")
+	rootCmd.PersistentFlags().StringVarP(&config.ImageNameConfigMap, "image-name-configmap", "", viper.GetString("IMAGE_NAME_CONFIGMAP"), "name of the ConfigMap of the Velero change image name plugin in the destination Velero namespace, when none exists yet")
+	rootCmd.PersistentFlags().BoolVarP(&config.KeepImageNameRules, "keep-image-name-rules", "", viper.GetBool("KEEP_IMAGE_NAME_RULES"), "Keep the image name rules in the ConfigMap after the restore instead of reverting them")
+	rootCmd.PersistentFlags().StringArrayVarP(&config.NamespaceMappingRules, "namespace-mapping-rule", "", viper.GetStringSlice("NAMESPACE_MAPPING_RULE"), "Rule mapping the included namespaces without --namespace-mapping: prefix=<prefix>, suffix=<suffix>, regex=<pattern>=><replacement> or template=<go-template>. Can be repeated, rules are applied in order")
+	rootCmd.PersistentFlags().StringVarP(&config.OnNamespaceConflict, "on-namespace-conflict", "", viper.GetString("ON_NAMESPACE_CONFLICT"), "What to do when a destination namespace holds objects or is terminating: fail, skip the namespace, or merge into it with existing-resource-policy update. Prompted when not given, fail in non-interactive mode")
 	rootCmd.PersistentFlags().BoolVarP(&config.VeleroRestoreOptions.RestorePVs, "restore-pvs", "p", viper.GetBool("RESTORE_PVS"), "Whether to restore all included PVs from snapshot")
 	rootCmd.PersistentFlags().BoolVarP(&config.VeleroRestoreOptions.PreserveNodePorts, "preserve-node-ports", "P", viper.GetBool("PRESERVE-NODE-PORTS"), "Whether to restore old nodePorts from backup")
 	rootCmd.PersistentFlags().StringVarP(&config.VeleroRestoreOptions.ExistingResourcePolicy, "existing-resource-policy", "E", viper.GetString("EXISTING_RESOURCE_POLICY"), "Restore behavior for the Kubernetes resource to be restored")
+	rootCmd.PersistentFlags().BoolVarP(&config.NonInteractive, "non-interactive", "", viper.GetBool("NON_INTERACTIVE"), "Never prompt, fail with every missing required value instead. Enabled automatically when stdin is not a terminal")
+	rootCmd.PersistentFlags().BoolVarP(&config.AssumeYes, "yes", "y", viper.GetBool("YES"), "Answer yes to every confirmation, implies --clone-velero and --use-source-kubeconfig")
+	rootCmd.PersistentFlags().BoolVarP(&config.CloneVelero, "clone-velero", "", viper.GetBool("CLONE_VELERO"), "Clone the Velero Helm release from the source cluster when no Velero server is discovered in the destination cluster")
+	rootCmd.PersistentFlags().BoolVarP(&config.UseSourceKubeconfig, "use-source-kubeconfig", "", viper.GetBool("USE_SOURCE_KUBECONFIG"), "Use the source kubeconfig as destination kubeconfig when none is given")
+	rootCmd.PersistentFlags().BoolVarP(&config.DryRun, "dry-run", "", viper.GetBool("DRY_RUN"), "Run the whole discovery flow and print the objects that would be written to the destination cluster without applying them")
+	rootCmd.PersistentFlags().StringVarP(&config.RestoreLogsDir, "restore-logs-dir", "", viper.GetString("RESTORE_LOGS_DIR"), "directory where the logs and results of a failed restore are saved")
+	rootCmd.PersistentFlags().BoolVarP(&config.InsecureSkipTLSVerify, "insecure-skip-tls-verify", "", viper.GetBool("INSECURE_SKIP_TLS_VERIFY"), "Skip the object storage certificate verification when downloading restore logs and results")
+	rootCmd.PersistentFlags().StringVarP(&config.VeleroRestoreOptions.ResourceModifierFile, "resource-modifier-file", "", viper.GetString("RESOURCE_MODIFIER_FILE"), "path to a Velero resource modifiers file, whose rules are added to the resource-modifiers of the config file")
+	rootCmd.PersistentFlags().StringVarP(&config.CACertFile, "cacert", "", viper.GetString("CACERT"), "path to a CA bundle used to verify the object storage certificate when downloading restore logs and results")
+	rootCmd.PersistentFlags().BoolVarP(&config.Verify, "verify", "", viper.GetBool("VERIFY"), "Once the restore is completed, wait for the restored Deployments, StatefulSets and DaemonSets to be ready, PersistentVolumeClaims to be Bound and Jobs to succeed")
+	rootCmd.PersistentFlags().DurationVarP(&config.VerifyTimeout, "verify-timeout", "", viper.GetDuration("VERIFY_TIMEOUT"), "Time to wait for the restored workloads to become healthy with --verify")
+	rootCmd.PersistentFlags().StringVarP(&config.Profile, "profile", "", viper.GetString("PROFILE"), "name of the profile of the config file to use")
+	addRunSummaryFlags(rootCmd)
+	addExportFlag(rootCmd)
+	registerFlagCompletions()
 	setDefaultSourceKubeconfig()
 	controller_logger.SetLogger(logr.Logger{})
 }
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	prompt "vresq/pkg/prompt"
+	velero "vresq/pkg/velero"
+)
+
+// buildStorageClassMapping maps the storage classes used by the volumes of the backup to storage classes of the destination cluster.
+// The storage classes which exist in the destination cluster are kept, the other ones without a --storage-class-mapping are matched
+// to the closest destination storage class, proposed first in interactive mode.
+func buildStorageClassMapping() (map[string]string, error) {
+	volumes, err := backupVolumes()
+	if err != nil {
+		return nil, err
+	}
+	usedStorageClasses := volumes.StorageClasses
+	if len(usedStorageClasses) == 0 {
+		log.Println("No storage class is used by the volumes of the backup")
+	} else {
+		log.Printf("Storage classes used by the volumes of the backup: %s", strings.Join(usedStorageClasses, ", "))
+	}
+	sourceStorageClasses, err := velero.ListStorageClasses(&sourceDynamiClient)
+	if err != nil {
+		return nil, fmt.Errorf("could not list storage classes in the source cluster: %v", err)
+	}
+	destinationStorageClasses, err := velero.ListStorageClasses(&destinationDynamiClient)
+	if err != nil {
+		return nil, fmt.Errorf("could not list storage classes in the destination cluster: %v", err)
+	}
+
+	sourceByName := map[string]velero.StorageClass{}
+	for _, storageClass := range sourceStorageClasses {
+		sourceByName[storageClass.Name] = storageClass
+	}
+	destinationNames := map[string]bool{}
+	for _, storageClass := range destinationStorageClasses {
+		destinationNames[storageClass.Name] = true
+	}
+	mapping := map[string]string{}
+	for source, target := range config.StorageClassMapping {
+		mapping[source] = target
+	}
+	for _, name := range usedStorageClasses {
+		_, mapped := mapping[name]
+		if !mapped && destinationNames[name] {
+			log.Printf("Storage class %s exists in the destination cluster, it is not mapped", name)
+			continue
+		}
+		// Without destination storage classes, the mapping fails with the unmapped ones
+		if mapped || len(destinationStorageClasses) == 0 {
+			continue
+		}
+		storageClass, known := sourceByName[name]
+		if !known {
+			// A storage class deleted from the source cluster since the backup cannot be compared, the default one is used
+			log.Printf("Warning: storage class %s does not exist in the source cluster anymore", name)
+			if config.NonInteractive {
+				continue
+			}
+			storageClass = velero.StorageClass{Name: name, Provisioner: "unknown"}
+		}
+
+		matches := velero.SortStorageClassesByMatch(storageClass, destinationStorageClasses)
+		match := matches[0]
+		if config.NonInteractive {
+			log.Printf("Storage class %s matched to %s: %s", name, match.StorageClass.Name, describeStorageClassMatch(match))
+		} else {
+			match = chooseStorageClass(storageClass, matches, known)
+		}
+		if known {
+			for _, warning := range match.Warnings {
+				log.Printf("Warning: storage class %s mapped to %s: %s", name, match.StorageClass.Name, warning)
+			}
+		}
+		mapping[name] = match.StorageClass.Name
+	}
+
+	result, err := velero.BuildStorageClassMapping(usedStorageClasses, destinationStorageClasses, mapping)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range sortedKeys(result) {
+		log.Printf("Storage class %s mapped to %s", source, result[source])
+	}
+	return result, nil
+}
+
+// chooseStorageClass prompts for the destination storage class of a source storage class among the given matches,
+// the closest one being proposed first.
+func chooseStorageClass(sourceStorageClass velero.StorageClass, matches []velero.StorageClassMatch, known bool) velero.StorageClassMatch {
+	choices := []string{}
+	byChoice := map[string]velero.StorageClassMatch{}
+	for i, match := range matches {
+		storageClass := match.StorageClass
+		details := fmt.Sprintf("provisioner %s, reclaim policy %s, binding mode %s", storageClass.Provisioner, storageClass.ReclaimPolicy, storageClass.VolumeBindingMode)
+		if storageClass.Default {
+			details = "default, " + details
+		}
+		if i == 0 && known {
+			details = fmt.Sprintf("proposed, %s: %s", describeStorageClassMatch(match), details)
+		}
+		choice := fmt.Sprintf("%s (%s)", storageClass.Name, details)
+		byChoice[choice] = match
+		choices = append(choices, choice)
+	}
+	label := fmt.Sprintf("Destination storage class for storage class '%s' (provisioner %s)", sourceStorageClass.Name, sourceStorageClass.Provisioner)
+	return byChoice[prompt.SelectChoice(label, choices)]
+}
+
+// describeStorageClassMatch explains why a destination storage class matches a source storage class.
+func describeStorageClassMatch(match velero.StorageClassMatch) string {
+	if len(match.Reasons) == 0 {
+		return "no common capability"
+	}
+	return strings.Join(match.Reasons, ", ")
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+	common "vresq/pkg/common"
+	kube "vresq/pkg/kubernetes"
+	velero "vresq/pkg/velero"
+
+	"github.com/spf13/cobra"
+)
+
+var outputFile string
+
+// runSummary is the result document of a vresq run, written at its end for automation.
+type runSummary struct {
+	RunID         string                  `json:"runID" yaml:"runID"`
+	DryRun        bool                    `json:"dryRun" yaml:"dryRun"`
+	StartedAt     string                  `json:"startedAt" yaml:"startedAt"`
+	CompletedAt   string                  `json:"completedAt" yaml:"completedAt"`
+	Duration      string                  `json:"duration" yaml:"duration"`
+	Config        common.Config           `json:"config" yaml:"config"`
+	Source        kube.ClusterIdentity    `json:"source" yaml:"source"`
+	Destination   kube.ClusterIdentity    `json:"destination" yaml:"destination"`
+	VeleroCloned  bool                    `json:"veleroCloned" yaml:"veleroCloned"`
+	Objects       []velero.ObjectRecord   `json:"objects" yaml:"objects"`
+	Restore       velero.RestoreSummary   `json:"restore" yaml:"restore"`
+	Verification  []velero.WorkloadStatus `json:"verification,omitempty" yaml:"verification,omitempty"`
+	VeleroCommand string                  `json:"veleroCommand" yaml:"veleroCommand"`
+	Error         string                  `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// addRunSummaryFlags registers the flags of the run summary on commands running the restore workflow.
+func addRunSummaryFlags(cmd *cobra.Command) {
+	addOutputFlag(cmd)
+	cmd.Flags().StringVar(&outputFile, "output-file", "", "Write the run summary to this file instead of the standard output")
+}
+
+// runSummaryRequested checks whether a run summary should be written, that is when --output is json or yaml, or --output-file is given.
+func runSummaryRequested() bool {
+	return outputFormat != outputTable || outputFile != ""
+}
+
+// runSummaryToStdout checks whether the run summary is written to the standard output.
+func runSummaryToStdout() bool {
+	return runSummaryRequested() && outputFile == ""
+}
+
+// newRunSummary builds the summary of the current run, started at startedAt.
+// verification is the status of the restored workloads when --verify is given, and restoreErr is the error of the restore creation
+// or verification, if any.
+func newRunSummary(startedAt time.Time, veleroCloned bool, verification []velero.WorkloadStatus, restoreErr error) runSummary {
+	completedAt := time.Now()
+	summary := runSummary{
+		RunID:        velero.GetRunID(),
+		DryRun:       config.DryRun,
+		StartedAt:    startedAt.UTC().Format(time.RFC3339),
+		CompletedAt:  completedAt.UTC().Format(time.RFC3339),
+		Duration:     completedAt.Sub(startedAt).Round(time.Second).String(),
+		Config:       config,
+		Source:       kube.GetClusterIdentity(&sourceDynamiClient, config.SourceKubeconfig, config.SourceContext),
+		Destination:  kube.GetClusterIdentity(&destinationDynamiClient, config.DestinationKubeconfig, config.DestinationContext),
+		VeleroCloned: veleroCloned,
+		Objects:      velero.GetObjectRecords(),
+		Restore: velero.RestoreSummary{
+			Name:       config.RestoreName,
+			BackupName: config.VeleroRestoreOptions.BackupName,
+		},
+		Verification:  verification,
+		VeleroCommand: veleroRestoreCommand(),
+	}
+	if restoreErr != nil {
+		summary.Error = restoreErr.Error()
+	}
+
+	// Nothing was created in dry-run mode
+	if !config.DryRun {
+		restore, err := velero.GetRestore(&destinationDynamiClient, config.DestinationVeleroNamespace, config.RestoreName)
+		if err != nil {
+			log.Printf("Warning: could not get restore %s for the run summary: %v", config.RestoreName, err)
+		} else {
+			summary.Restore = velero.GetRestoreSummary(restore)
+		}
+	}
+	return summary
+}
+
+// writeRunSummary writes the run summary to the standard output or to the --output-file, in the --output format.
+func writeRunSummary(summary runSummary) error {
+	var w io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf("could not create %s: %v", outputFile, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return printOutput(w, outputFormat, summary, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "Run ID:\t%s\n", summary.RunID)
+		fmt.Fprintf(tw, "Dry Run:\t%t\n", summary.DryRun)
+		fmt.Fprintf(tw, "Duration:\t%s\n", summary.Duration)
+		fmt.Fprintf(tw, "Source:\t%s (%s)\n", valueOrNone(summary.Source.Context), valueOrNone(summary.Source.Server))
+		fmt.Fprintf(tw, "Destination:\t%s (%s)\n", valueOrNone(summary.Destination.Context), valueOrNone(summary.Destination.Server))
+		fmt.Fprintf(tw, "Velero Cloned:\t%t\n", summary.VeleroCloned)
+		for _, object := range summary.Objects {
+			fmt.Fprintf(tw, "Object:\t%s %s/%s %s\n", object.Kind, object.Namespace, object.Name, object.Action)
+		}
+		fmt.Fprintf(tw, "Restore:\t%s\n", valueOrNone(summary.Restore.Name))
+		fmt.Fprintf(tw, "Backup:\t%s\n", valueOrNone(summary.Restore.BackupName))
+		fmt.Fprintf(tw, "Phase:\t%s\n", valueOrNone(summary.Restore.Phase))
+		fmt.Fprintf(tw, "Errors:\t%d\n", summary.Restore.Errors)
+		fmt.Fprintf(tw, "Warnings:\t%d\n", summary.Restore.Warnings)
+		for _, status := range summary.Verification {
+			fmt.Fprintf(tw, "Workload:\t%s %s/%s %s (%s)\n", status.Kind, status.Namespace, status.Name, status.Status, status.Message)
+		}
+		if summary.Error != "" {
+			fmt.Fprintf(tw, "Error:\t%s\n", summary.Error)
+		}
+	})
+}
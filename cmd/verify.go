@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"log"
+	velero "vresq/pkg/velero"
+)
+
+// verifyRestoredWorkloads waits for the workloads restored in the destination namespaces of the restore to become healthy,
+// and logs the status of each of them.
+func verifyRestoredWorkloads() ([]velero.WorkloadStatus, error) {
+	namespaces := velero.RestoreTargetNamespaces(config.VeleroRestoreOptions)
+	statuses, err := velero.VerifyRestoredWorkloads(&destinationDynamiClient, config.RestoreName, namespaces, config.VerifyTimeout)
+	for _, status := range statuses {
+		log.Printf("%s %s/%s: %s (%s)", status.Kind, status.Namespace, status.Name, status.Status, status.Message)
+	}
+	if err == nil {
+		log.Printf("All %d restored workloads are healthy", len(statuses))
+	}
+	return statuses, err
+}
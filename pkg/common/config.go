@@ -10,34 +10,137 @@ const (
 	VeleroApiGroup = "velero.io"
 	ApiVersion     = "v1"
 	ConfigMapName  = "change-storage-class-config"
+	// NodeSelectorConfigMapName and ImageNameConfigMapName are the default names of the ConfigMaps of the other Velero plugins vresq configures.
+	NodeSelectorConfigMapName = "change-pvc-node-selector-config"
+	ImageNameConfigMapName    = "change-image-name-config"
 )
 
 // Config holds configuration parameters
 type Config struct {
-	SourceContext               string `mapstructure:"source-context"`
-	DestinationContext          string `mapstructure:"destination-context"`
-	SourceKubeconfig            string `mapstructure:"source-kubeconfig"`
-	DestinationKubeconfig       string `mapstructure:"destination-kubeconfig"`
-	RestoreName                 string `mapstructure:"restore-name"`
-	SourceVeleroHelmReleaseName string `mapstructure:"source-velero-helm-release-name"`
-	SourceVeleroNamespace       string `mapstructure:"source-velero-namespace"`
-	DestinationVeleroNamespace  string `mapstructure:"destination-velero-namespace"`
-	VeleroRestoreOptions        VeleroRestoreOptions
+	SourceContext               string               `mapstructure:"source-context" json:"sourceContext" yaml:"sourceContext"`
+	DestinationContext          string               `mapstructure:"destination-context" json:"destinationContext" yaml:"destinationContext"`
+	SourceKubeconfig            string               `mapstructure:"source-kubeconfig" json:"sourceKubeconfig" yaml:"sourceKubeconfig"`
+	DestinationKubeconfig       string               `mapstructure:"destination-kubeconfig" json:"destinationKubeconfig" yaml:"destinationKubeconfig"`
+	RestoreName                 string               `mapstructure:"restore-name" json:"restoreName" yaml:"restoreName"`
+	SourceVeleroHelmReleaseName string               `mapstructure:"source-velero-helm-release-name" json:"sourceVeleroHelmReleaseName" yaml:"sourceVeleroHelmReleaseName"`
+	SourceVeleroNamespace       string               `mapstructure:"source-velero-namespace" json:"sourceVeleroNamespace" yaml:"sourceVeleroNamespace"`
+	DestinationVeleroNamespace  string               `mapstructure:"destination-velero-namespace" json:"destinationVeleroNamespace" yaml:"destinationVeleroNamespace"`
+	NonInteractive              bool                 `mapstructure:"non-interactive" json:"nonInteractive" yaml:"nonInteractive"`
+	AssumeYes                   bool                 `mapstructure:"yes" json:"assumeYes" yaml:"assumeYes"`
+	CloneVelero                 bool                 `mapstructure:"clone-velero" json:"cloneVelero" yaml:"cloneVelero"`
+	UseSourceKubeconfig         bool                 `mapstructure:"use-source-kubeconfig" json:"useSourceKubeconfig" yaml:"useSourceKubeconfig"`
+	DryRun                      bool                 `mapstructure:"dry-run" json:"dryRun" yaml:"dryRun"`
+	RestoreLogsDir              string               `mapstructure:"restore-logs-dir" json:"restoreLogsDir" yaml:"restoreLogsDir"`
+	InsecureSkipTLSVerify       bool                 `mapstructure:"insecure-skip-tls-verify" json:"insecureSkipTLSVerify" yaml:"insecureSkipTLSVerify"`
+	CACertFile                  string               `mapstructure:"cacert" json:"caCertFile" yaml:"caCertFile"`
+	Profile                     string               `mapstructure:"profile" json:"profile,omitempty" yaml:"profile,omitempty"`
+	NamespaceMappingRules       []string             `mapstructure:"namespace-mapping-rule" json:"namespaceMappingRules,omitempty" yaml:"namespaceMappingRules,omitempty"`
+	StorageClassMapping         map[string]string    `mapstructure:"storage-class-mapping" json:"storageClassMapping,omitempty" yaml:"storageClassMapping,omitempty"`
+	StorageClassConfigMap       string               `mapstructure:"storage-class-configmap" json:"storageClassConfigMap" yaml:"storageClassConfigMap"`
+	KeepStorageClassMapping     bool                 `mapstructure:"keep-storage-class-mapping" json:"keepStorageClassMapping" yaml:"keepStorageClassMapping"`
+	NodeMapping                 map[string]string    `mapstructure:"node-mapping" json:"nodeMapping,omitempty" yaml:"nodeMapping,omitempty"`
+	NodeSelectorConfigMap       string               `mapstructure:"node-selector-configmap" json:"nodeSelectorConfigMap" yaml:"nodeSelectorConfigMap"`
+	KeepNodeMapping             bool                 `mapstructure:"keep-node-mapping" json:"keepNodeMapping" yaml:"keepNodeMapping"`
+	ImageNameRules              []string             `mapstructure:"image-name-rule" json:"imageNameRules,omitempty" yaml:"imageNameRules,omitempty"`
+	ImageNameConfigMap          string               `mapstructure:"image-name-configmap" json:"imageNameConfigMap" yaml:"imageNameConfigMap"`
+	KeepImageNameRules          bool                 `mapstructure:"keep-image-name-rules" json:"keepImageNameRules" yaml:"keepImageNameRules"`
+	OnNamespaceConflict         string               `mapstructure:"on-namespace-conflict" json:"onNamespaceConflict,omitempty" yaml:"onNamespaceConflict,omitempty"`
+	Verify                      bool                 `mapstructure:"verify" json:"verify" yaml:"verify"`
+	VerifyTimeout               time.Duration        `mapstructure:"verify-timeout" json:"verifyTimeout" yaml:"verifyTimeout"`
+	VeleroRestoreOptions        VeleroRestoreOptions `json:"veleroRestoreOptions" yaml:"veleroRestoreOptions"`
 }
 
 type VeleroRestoreOptions struct {
-	BackupName              string            `mapstructure:"backup-name"`
-	ScheduleName            string            `mapstructure:"schedule-name"`
-	ItemOperationTimeout    time.Duration     `mapstructure:"item-operation-timeout"`
-	IncludedNamespaces      []string          `mapstructure:"included-namespaces"`
-	ExcludedNamespaces      []string          `mapstructure:"excluded-namespaces"`
-	IncludedResources       []string          `mapstructure:"included-resources"`
-	ExcludedResources       []string          `mapstructure:"excluded-resources"`
-	IncludeClusterResources bool              `mapstructure:"include-cluster-resources"`
-	LabelSelector           map[string]string `mapstructure:"label-selector"`
-	OrLabelSelectors        map[string]string `mapstructure:"or-label-selectors"`
-	NamespaceMapping        map[string]string `mapstructure:"namespace-mapping"`
-	RestorePVs              bool              `mapstructure:"restore-pvs"`
-	PreserveNodePorts       bool              `mapstructure:"preserve-node-ports"`
-	ExistingResourcePolicy  string            `mapstructure:"existing-resource-policy"`
+	BackupName              string             `mapstructure:"backup-name" json:"backupName" yaml:"backupName"`
+	ScheduleName            string             `mapstructure:"schedule-name" json:"scheduleName" yaml:"scheduleName"`
+	AllowPartiallyFailed    bool               `mapstructure:"allow-partially-failed" json:"allowPartiallyFailed" yaml:"allowPartiallyFailed"`
+	ItemOperationTimeout    time.Duration      `mapstructure:"item-operation-timeout" json:"itemOperationTimeout" yaml:"itemOperationTimeout"`
+	IncludedNamespaces      []string           `mapstructure:"included-namespaces" json:"includedNamespaces" yaml:"includedNamespaces"`
+	ExcludedNamespaces      []string           `mapstructure:"excluded-namespaces" json:"excludedNamespaces" yaml:"excludedNamespaces"`
+	IncludedResources       []string           `mapstructure:"included-resources" json:"includedResources" yaml:"includedResources"`
+	ExcludedResources       []string           `mapstructure:"excluded-resources" json:"excludedResources" yaml:"excludedResources"`
+	IncludeClusterResources bool               `mapstructure:"include-cluster-resources" json:"includeClusterResources" yaml:"includeClusterResources"`
+	LabelSelector           string             `mapstructure:"label-selector" json:"labelSelector" yaml:"labelSelector"`
+	OrLabelSelectors        []string           `mapstructure:"or-label-selectors" json:"orLabelSelectors" yaml:"orLabelSelectors"`
+	NamespaceMapping        map[string]string  `mapstructure:"namespace-mapping" json:"namespaceMapping" yaml:"namespaceMapping"`
+	RestorePVs              bool               `mapstructure:"restore-pvs" json:"restorePVs" yaml:"restorePVs"`
+	PreserveNodePorts       bool               `mapstructure:"preserve-node-ports" json:"preserveNodePorts" yaml:"preserveNodePorts"`
+	ExistingResourcePolicy  string             `mapstructure:"existing-resource-policy" json:"existingResourcePolicy" yaml:"existingResourcePolicy"`
+	RestoreHooks            []RestoreHook      `mapstructure:"restore-hooks" json:"restoreHooks,omitempty" yaml:"restoreHooks,omitempty"`
+	ResourceModifiers       []ResourceModifier `mapstructure:"resource-modifiers" json:"resourceModifiers,omitempty" yaml:"resourceModifiers,omitempty"`
+	ResourceModifierFile    string             `mapstructure:"resource-modifier-file" json:"resourceModifierFile,omitempty" yaml:"resourceModifierFile,omitempty"`
+}
+
+// ResourceModifier holds JSON patches applied by Velero to the restored objects matching its conditions.
+type ResourceModifier struct {
+	GroupResource     string            `mapstructure:"group-resource" json:"groupResource" yaml:"groupResource"`
+	ResourceNameRegex string            `mapstructure:"resource-name-regex" json:"resourceNameRegex,omitempty" yaml:"resourceNameRegex,omitempty"`
+	Namespaces        []string          `mapstructure:"namespaces" json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+	LabelSelector     map[string]string `mapstructure:"label-selector" json:"labelSelector,omitempty" yaml:"labelSelector,omitempty"`
+	Patches           []JSONPatch       `mapstructure:"patches" json:"patches" yaml:"patches"`
+}
+
+// JSONPatch is a JSON patch operation of a resource modifier.
+// Its value is a string, a number or a boolean. JSON objects and arrays are given as strings, since the config file keys are case-insensitive.
+type JSONPatch struct {
+	Operation string      `mapstructure:"operation" json:"operation" yaml:"operation"`
+	From      string      `mapstructure:"from" json:"from,omitempty" yaml:"from,omitempty"`
+	Path      string      `mapstructure:"path" json:"path" yaml:"path"`
+	Value     interface{} `mapstructure:"value" json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// RestoreHook holds the post-restore hooks run on the restored pods matching its scope, as Velero restore hooks.
+type RestoreHook struct {
+	Name               string            `mapstructure:"name" json:"name" yaml:"name"`
+	IncludedNamespaces []string          `mapstructure:"included-namespaces" json:"includedNamespaces,omitempty" yaml:"includedNamespaces,omitempty"`
+	ExcludedNamespaces []string          `mapstructure:"excluded-namespaces" json:"excludedNamespaces,omitempty" yaml:"excludedNamespaces,omitempty"`
+	IncludedResources  []string          `mapstructure:"included-resources" json:"includedResources,omitempty" yaml:"includedResources,omitempty"`
+	ExcludedResources  []string          `mapstructure:"excluded-resources" json:"excludedResources,omitempty" yaml:"excludedResources,omitempty"`
+	LabelSelector      map[string]string `mapstructure:"label-selector" json:"labelSelector,omitempty" yaml:"labelSelector,omitempty"`
+	PostHooks          []RestorePostHook `mapstructure:"post-hooks" json:"postHooks" yaml:"postHooks"`
+}
+
+// RestorePostHook is either an init hook or an exec hook.
+type RestorePostHook struct {
+	Init *RestoreInitHook `mapstructure:"init" json:"init,omitempty" yaml:"init,omitempty"`
+	Exec *RestoreExecHook `mapstructure:"exec" json:"exec,omitempty" yaml:"exec,omitempty"`
+}
+
+// RestoreInitHook adds init containers to the restored pods.
+type RestoreInitHook struct {
+	InitContainers []HookContainer `mapstructure:"init-containers" json:"initContainers" yaml:"initContainers"`
+	Timeout        time.Duration   `mapstructure:"timeout" json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// HookContainer is an init container added by a restore hook.
+type HookContainer struct {
+	Name         string            `mapstructure:"name" json:"name" yaml:"name"`
+	Image        string            `mapstructure:"image" json:"image" yaml:"image"`
+	Command      []string          `mapstructure:"command" json:"command,omitempty" yaml:"command,omitempty"`
+	Args         []string          `mapstructure:"args" json:"args,omitempty" yaml:"args,omitempty"`
+	Env          []HookEnvVar      `mapstructure:"env" json:"env,omitempty" yaml:"env,omitempty"`
+	VolumeMounts []HookVolumeMount `mapstructure:"volume-mounts" json:"volumeMounts,omitempty" yaml:"volumeMounts,omitempty"`
+}
+
+// HookEnvVar is an environment variable of a hook init container.
+type HookEnvVar struct {
+	Name  string `mapstructure:"name" json:"name" yaml:"name"`
+	Value string `mapstructure:"value" json:"value" yaml:"value"`
+}
+
+// HookVolumeMount mounts a volume of the restored pod in a hook init container.
+type HookVolumeMount struct {
+	Name      string `mapstructure:"name" json:"name" yaml:"name"`
+	MountPath string `mapstructure:"mount-path" json:"mountPath" yaml:"mountPath"`
+	ReadOnly  bool   `mapstructure:"read-only" json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+}
+
+// RestoreExecHook runs a command in a container of the restored pods.
+type RestoreExecHook struct {
+	Container    string        `mapstructure:"container" json:"container,omitempty" yaml:"container,omitempty"`
+	Command      []string      `mapstructure:"command" json:"command" yaml:"command"`
+	OnError      string        `mapstructure:"on-error" json:"onError,omitempty" yaml:"onError,omitempty"`
+	ExecTimeout  time.Duration `mapstructure:"exec-timeout" json:"execTimeout,omitempty" yaml:"execTimeout,omitempty"`
+	WaitTimeout  time.Duration `mapstructure:"wait-timeout" json:"waitTimeout,omitempty" yaml:"waitTimeout,omitempty"`
+	WaitForReady bool          `mapstructure:"wait-for-ready" json:"waitForReady,omitempty" yaml:"waitForReady,omitempty"`
 }
@@ -1,10 +1,14 @@
 package kubernetes
 
 import (
+	"context"
 	"log"
 	"vresq/pkg/common"
 
 	helm "github.com/mittwald/go-helm-client"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -71,6 +75,19 @@ func GetKubernetesClientWithContext(kubeconfig, contextName string) dynamic.Dyna
 	return *dynamicClient
 }
 
+// GetDiscoveryClientWithContext returns a discovery client based on the provided kubeconfig and context.
+func GetDiscoveryClientWithContext(kubeconfig, contextName string) discovery.DiscoveryInterface {
+	config, err := buildConfigWithContextFromFlags(contextName, kubeconfig)
+	if err != nil {
+		log.Fatalf("Error building Kubernetes config: %v", err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		log.Fatalf("Fail to create the k8s discovery client. Error: %v", err)
+	}
+	return discoveryClient
+}
+
 // GetHelmClientWithContext returns a Helm client based on the provided kubeconfig, context, and namespace.
 func GetHelmClientWithContext(kubeconfig, contextName, namespace string) helm.Client {
 	config, err := buildConfigWithContextFromFlags(contextName, kubeconfig)
@@ -135,3 +152,45 @@ func buildConfigWithContextFromFlags(context string, kubeconfigPath string) (*re
 			CurrentContext: context,
 		}).ClientConfig()
 }
+
+// ClusterIdentity identifies a Kubernetes cluster by its kubeconfig context and API server, and by the UID of its kube-system namespace.
+type ClusterIdentity struct {
+	Kubeconfig string `json:"kubeconfig" yaml:"kubeconfig"`
+	Context    string `json:"context" yaml:"context"`
+	Cluster    string `json:"cluster" yaml:"cluster"`
+	Server     string `json:"server" yaml:"server"`
+	ClusterID  string `json:"clusterID,omitempty" yaml:"clusterID,omitempty"`
+}
+
+// GetClusterIdentity resolves the context, cluster and API server of the given kubeconfig and context.
+// The empty context stands for the current context of the kubeconfig.
+func GetClusterIdentity(dynamicClient dynamic.Interface, kubeconfig, contextName string) ClusterIdentity {
+	identity := ClusterIdentity{
+		Kubeconfig: kubeconfig,
+		Context:    contextName,
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{
+			CurrentContext: contextName,
+		})
+	rawConfig, err := clientConfig.RawConfig()
+	if err == nil {
+		if identity.Context == "" {
+			identity.Context = rawConfig.CurrentContext
+		}
+		if kubeContext, found := rawConfig.Contexts[identity.Context]; found {
+			identity.Cluster = kubeContext.Cluster
+			if cluster, found := rawConfig.Clusters[kubeContext.Cluster]; found {
+				identity.Server = cluster.Server
+			}
+		}
+	}
+
+	// The kube-system namespace lives as long as the cluster, its UID is commonly used as a cluster ID
+	kubeSystem, err := dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}).Get(context.TODO(), "kube-system", metav1.GetOptions{})
+	if err == nil {
+		identity.ClusterID = string(kubeSystem.GetUID())
+	}
+	return identity
+}
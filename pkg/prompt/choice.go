@@ -3,6 +3,7 @@ package prompt
 import (
 	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 	"time"
@@ -47,7 +48,7 @@ func ChooseRestoreName() (string, error) {
 	}
 	result, err := prompt.Run()
 	if err != nil {
-		fmt.Printf("Prompt failed %v\n", err)
+		log.Printf("Prompt failed %v", err)
 		return "", err
 	}
 	return result, nil
@@ -23,6 +23,11 @@ const (
 	toBold             = "{{ . | bold }} "
 )
 
+// namespaceRegex matches a DNS-1123 label, the format of namespace names, of at most 63 characters.
+var namespaceRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+const namespaceValidationError = "namespace name should be at most 63 lowercase alphanumeric characters or '-', starting and ending with an alphanumeric character"
+
 // Context represents a Kubernetes context.
 type Context struct {
 	Context struct {
@@ -56,12 +61,17 @@ func ConfirmUserChoice(label string) bool {
 	return promptWithSelect(fmt.Sprintf("%s?", label), []string{ConfirmYes, ConfirmNo}) == ConfirmYes
 }
 
-// promptWithSelect prompts the user to select from a list of choices.
+// SelectChoice prompts the user to select one of the given choices.
+func SelectChoice(label string, choices []string) string {
+	return promptWithSelect(label, choices)
+}
+
+// promptWithSelect prompts the user to select from a list of choices, all of them being shown.
 func promptWithSelect(label string, choices []string) string {
 	prompt := promptui.Select{
 		Label: label,
 		Items: choices,
-		Size:  2,
+		Size:  len(choices),
 	}
 
 	_, selected, err := prompt.Run()
@@ -86,7 +96,7 @@ func promptWithValidate(label string, validate func(string) error, entity string
 
 	result, err := prompt.Run()
 	if err != nil {
-		fmt.Printf("Prompt failed %v\n", err)
+		log.Printf("Prompt failed %v", err)
 		return "", err
 	}
 
@@ -128,6 +138,11 @@ func UserInput(regex *regexp.Regexp, validationError string, label string, defau
 	return result, nil
 }
 
+// NamespaceInput prompts the user for a namespace name, which must be a valid DNS-1123 label.
+func NamespaceInput(label string, defaultValue string) (string, error) {
+	return UserInput(namespaceRegex, namespaceValidationError, label, defaultValue)
+}
+
 // selectItems prompts the user to select items from a list.
 func selectItems(selectedPos int, allItems []*item, label string) ([]*item, error) {
 	const doneID = "Done"
@@ -65,6 +65,19 @@ func ChooseDestinationKubeconfig() (string, error) {
 	return promptWithValidate(label, validate, "kubeconfig", "")
 }
 
+// GetKubeconfigContextNames returns the names of the contexts defined in the kubeconfig file.
+func GetKubeconfigContextNames(kubeconfigPath string) ([]string, error) {
+	contexts, err := getKubeconfigContexts(kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, context := range contexts {
+		names = append(names, context.Name)
+	}
+	return names, nil
+}
+
 // getKubeconfigContexts reads the kubeconfig file and returns a list of contexts.
 func getKubeconfigContexts(kubeconfigPath string) ([]Context, error) {
 	// Read the kubeconfig file
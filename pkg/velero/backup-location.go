@@ -40,16 +40,25 @@ func SetupVeleroBackupLocation(sourceDynamicClient dynamic.Interface, destinatio
 	// List destination backup storage locations
 	destinationBackupLocations, err := listBackupStorageLocations(destinationDynamicClient, config.DestinationVeleroNamespace)
 	if err != nil {
-		log.Fatalf("Error: could not list Backup storage locations in destination namespace, %v", err)
+		// In dry-run mode, Velero may not be installed yet in the destination cluster
+		if !IsDryRun() {
+			log.Fatalf("Error: could not list Backup storage locations in destination namespace, %v", err)
+		}
+		log.Printf("Warning: could not list Backup storage locations in destination namespace, %v", err)
+		destinationBackupLocations = &unstructured.UnstructuredList{}
 	}
 	// Check if the destination backup storage location exists
-	foundStorageLocation := findDestinationStorageLocation(&sourceBackupLocation, destinationBackupLocations.Items)
+	destinationBackupLocationName, foundStorageLocation := findDestinationStorageLocation(&sourceBackupLocation, destinationBackupLocations.Items)
 	if !foundStorageLocation {
 		// If not found, create a new backup storage location in the destination cluster
 		log.Printf("Did not find any backup storage location in destination cluster with source BackupStorageLocation: %s, creating one ...", sourceBackupLocation.GetName())
 		sourceBucketName := sourceBackupLocation.UnstructuredContent()["spec"].(map[string]interface{})["objectStorage"].(map[string]interface{})["bucket"].(string)
 		SetupDestinationBackupLocationSecret(sourceDynamicClient, destinationDynamicClient, &sourceBackupLocation, sourceBucketName, config)
 		createVeleroBackupStorageLocation(destinationDynamicClient, config.DestinationVeleroNamespace, fmt.Sprintf("%s-readonly", sourceBucketName), sourceBackupLocation.UnstructuredContent()["spec"].(map[string]interface{}))
+		// Nothing was created in dry-run mode, the backup will never be synchronized
+		if IsDryRun() {
+			return fmt.Sprintf("%s-readonly", sourceBucketName)
+		}
 		groupVersionResource := schema.GroupVersionResource{
 			Group:    veleroApiGroup,
 			Version:  apiVersion,
@@ -65,7 +74,8 @@ func SetupVeleroBackupLocation(sourceDynamicClient dynamic.Interface, destinatio
 		}
 		return fmt.Sprintf("%s-readonly", sourceBucketName)
 	}
-	return ""
+	recordObject("BackupStorageLocation", config.DestinationVeleroNamespace, destinationBackupLocationName, ObjectActionReused)
+	return destinationBackupLocationName
 }
 
 // SetupDestinationBackupLocationSecret sets up the secret for the destination backup location.
@@ -136,7 +146,9 @@ func handleNoCredentials(sourceDynamicClient dynamic.Interface, destinationDynam
 }
 
 // findDestinationStorageLocation checks if there is a BackupStorageLocation with the same specs as the source one in destination cluster.
-func findDestinationStorageLocation(sourceBackupLocation *unstructured.Unstructured, destinationBackupLocations []unstructured.Unstructured) bool {
+// It returns the name of the matching BackupStorageLocation and a boolean indicating if one is found.
+func findDestinationStorageLocation(sourceBackupLocation *unstructured.Unstructured, destinationBackupLocations []unstructured.Unstructured) (string, bool) {
+	foundStorageLocationName := ""
 	foundStorageLocation := false
 	for _, destinationBackupLocation := range destinationBackupLocations {
 		// Check if configurations match
@@ -153,10 +165,11 @@ func findDestinationStorageLocation(sourceBackupLocation *unstructured.Unstructu
 		availabilityCheck := sourceBackupLocation.UnstructuredContent()["status"].(map[string]interface{})["phase"].(string) == "Available"
 		// If all checks pass, set foundStorageLocation to true
 		if configCheck && objectStorageCheck && availabilityCheck {
+			foundStorageLocationName = destinationBackupLocation.GetName()
 			foundStorageLocation = true
 		}
 	}
-	return foundStorageLocation
+	return foundStorageLocationName, foundStorageLocation
 }
 
 // getBackupStorageLocation retrieves the backup storage location.
@@ -198,7 +211,7 @@ func createVeleroBackupStorageLocation(dynamicClient dynamic.Interface, namespac
 	err := createResource(dynamicClient, namespace, &backupStorageLocation, "backupstoragelocations")
 	if err != nil {
 		return err
-	} else {
+	} else if !IsDryRun() {
 		log.Println("BackupStorageLocation created successfully")
 	}
 
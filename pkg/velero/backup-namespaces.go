@@ -0,0 +1,80 @@
+package velero
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// GetBackupNamespaces returns the namespaces of a backup which are restored with the given excluded namespaces.
+// They are read from the included namespaces of the backup when it lists them explicitly, otherwise from its contents
+// downloaded through a DownloadRequest.
+func GetBackupNamespaces(dynamicClient dynamic.Interface, namespace string, backupName string, excludedNamespaces []string, options DownloadOptions) ([]string, error) {
+	backup, err := GetBackup(dynamicClient, namespace, backupName)
+	if err != nil {
+		return nil, fmt.Errorf("could not get backup %s: %v", backupName, err)
+	}
+	includedNamespaces, _, _ := unstructured.NestedStringSlice(backup.Object, "spec", "includedNamespaces")
+	explicit := len(includedNamespaces) > 0
+	for _, includedNamespace := range includedNamespaces {
+		if includedNamespace == "*" {
+			explicit = false
+		}
+	}
+	if explicit {
+		namespaces := map[string]bool{}
+		for _, includedNamespace := range includedNamespaces {
+			if namespaceIncluded(includedNamespace, nil, excludedNamespaces) {
+				namespaces[includedNamespace] = true
+			}
+		}
+		return sortedSet(namespaces), nil
+	}
+
+	reader, err := Download(dynamicClient, namespace, DownloadTargetKindBackupContents, backupName, options)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readBackupNamespaces(reader, backupName, excludedNamespaces)
+}
+
+// readBackupNamespaces reads the namespaces of the objects of a backup from its contents.
+func readBackupNamespaces(reader io.Reader, backupName string, excludedNamespaces []string) ([]string, error) {
+	// Namespaced objects are stored as resources/<resource>[/<version>-preferredversion]/namespaces/<namespace>/<name>.json,
+	// and namespaces themselves as resources/namespaces[/<version>-preferredversion]/cluster/<namespace>.json
+	namespaces := map[string]bool{}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read contents of backup %s: %v", backupName, err)
+		}
+		parts := strings.Split(header.Name, "/")
+		if len(parts) < 4 || parts[0] != "resources" || path.Ext(header.Name) != ".json" {
+			continue
+		}
+		namespace := ""
+		if parts[1] == "namespaces" && parts[len(parts)-2] == "cluster" {
+			namespace = strings.TrimSuffix(parts[len(parts)-1], ".json")
+		}
+		for i := 2; i < len(parts)-2; i++ {
+			if parts[i] == "namespaces" {
+				namespace = parts[i+1]
+				break
+			}
+		}
+		if namespace != "" && namespaceIncluded(namespace, nil, excludedNamespaces) {
+			namespaces[namespace] = true
+		}
+	}
+	return sortedSet(namespaces), nil
+}
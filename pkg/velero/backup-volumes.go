@@ -0,0 +1,104 @@
+package velero
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// selectedNodeAnnotation is the annotation set by the scheduler on the PersistentVolumeClaims of volumes provisioned on a given node,
+// like local volumes.
+const selectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+
+// BackupVolumes describes the volumes restored from a backup.
+type BackupVolumes struct {
+	// StorageClasses are the storage classes of the PersistentVolumeClaims and PersistentVolumes.
+	StorageClasses []string
+	// SelectedNodes are the nodes the PersistentVolumeClaims were provisioned on.
+	SelectedNodes []string
+}
+
+// GetBackupVolumes downloads the contents of a backup through a DownloadRequest and describes its PersistentVolumeClaims
+// and PersistentVolumes which are restored with the given included and excluded namespaces.
+func GetBackupVolumes(dynamicClient dynamic.Interface, namespace string, backupName string, includedNamespaces []string, excludedNamespaces []string, options DownloadOptions) (BackupVolumes, error) {
+	reader, err := Download(dynamicClient, namespace, DownloadTargetKindBackupContents, backupName, options)
+	if err != nil {
+		return BackupVolumes{}, err
+	}
+	defer reader.Close()
+	return readBackupVolumes(reader, backupName, includedNamespaces, excludedNamespaces)
+}
+
+// readBackupVolumes reads the storage classes and the selected nodes of the restored PersistentVolumeClaims and PersistentVolumes
+// from the contents of a backup.
+func readBackupVolumes(reader io.Reader, backupName string, includedNamespaces []string, excludedNamespaces []string) (BackupVolumes, error) {
+	// The backup is a tarball with one JSON file per object, like resources/persistentvolumeclaims/namespaces/<namespace>/<name>.json
+	storageClasses := map[string]bool{}
+	selectedNodes := map[string]bool{}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return BackupVolumes{}, fmt.Errorf("could not read contents of backup %s: %v", backupName, err)
+		}
+		parts := strings.Split(header.Name, "/")
+		if len(parts) < 3 || parts[0] != "resources" || path.Ext(header.Name) != ".json" {
+			continue
+		}
+		if parts[1] != persistentVolumeClaimGVR.Resource && parts[1] != "persistentvolumes" {
+			continue
+		}
+		object := unstructured.Unstructured{}
+		if err := json.NewDecoder(tarReader).Decode(&object.Object); err != nil {
+			return BackupVolumes{}, fmt.Errorf("could not decode %s of backup %s: %v", header.Name, backupName, err)
+		}
+		// PersistentVolumes are restored along with their claim
+		objectNamespace := object.GetNamespace()
+		if parts[1] == "persistentvolumes" {
+			objectNamespace = nestedString(object.Object, "spec", "claimRef", "namespace")
+		}
+		if !namespaceIncluded(objectNamespace, includedNamespaces, excludedNamespaces) {
+			continue
+		}
+		if storageClass := volumeStorageClass(object); storageClass != "" {
+			storageClasses[storageClass] = true
+		}
+		if node := object.GetAnnotations()[selectedNodeAnnotation]; node != "" && parts[1] == persistentVolumeClaimGVR.Resource {
+			selectedNodes[node] = true
+		}
+	}
+	return BackupVolumes{StorageClasses: sortedSet(storageClasses), SelectedNodes: sortedSet(selectedNodes)}, nil
+}
+
+// ListPersistentVolumeClaimVolumes describes the PersistentVolumeClaims of a cluster in the given included and excluded namespaces.
+func ListPersistentVolumeClaimVolumes(dynamicClient dynamic.Interface, includedNamespaces []string, excludedNamespaces []string) (BackupVolumes, error) {
+	list, err := dynamicClient.Resource(persistentVolumeClaimGVR).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return BackupVolumes{}, err
+	}
+	storageClasses := map[string]bool{}
+	selectedNodes := map[string]bool{}
+	for _, item := range list.Items {
+		if !namespaceIncluded(item.GetNamespace(), includedNamespaces, excludedNamespaces) {
+			continue
+		}
+		if storageClass := volumeStorageClass(item); storageClass != "" {
+			storageClasses[storageClass] = true
+		}
+		if node := item.GetAnnotations()[selectedNodeAnnotation]; node != "" {
+			selectedNodes[node] = true
+		}
+	}
+	return BackupVolumes{StorageClasses: sortedSet(storageClasses), SelectedNodes: sortedSet(selectedNodes)}, nil
+}
@@ -95,6 +95,43 @@ func GetBackup(dynamicClient dynamic.Interface, namespace string, name string) (
 	return *backup, nil
 }
 
+// GetLatestScheduleBackup retrieves the most recent Completed backup created by a Velero schedule,
+// or PartiallyFailed if allowPartiallyFailed is set. Backups are matched with the velero.io/schedule-name label.
+func GetLatestScheduleBackup(dynamicClient dynamic.Interface, namespace string, scheduleName string, allowPartiallyFailed bool) (unstructured.Unstructured, error) {
+	backups, err := dynamicClient.Resource(backupGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("velero.io/schedule-name=%s", scheduleName),
+	})
+	if err != nil {
+		return unstructured.Unstructured{}, err
+	}
+
+	var latestBackup *unstructured.Unstructured
+	var latestTimestamp time.Time
+	for i, backup := range backups.Items {
+		phase := nestedString(backup.Object, "status", "phase")
+		if phase != "Completed" && !(allowPartiallyFailed && phase == "PartiallyFailed") {
+			continue
+		}
+		// Fall back to the creation timestamp when the start timestamp is missing
+		timestamp, err := time.Parse(time.RFC3339, nestedString(backup.Object, "status", "startTimestamp"))
+		if err != nil {
+			timestamp = backup.GetCreationTimestamp().Time
+		}
+		if latestBackup == nil || timestamp.After(latestTimestamp) {
+			latestBackup = &backups.Items[i]
+			latestTimestamp = timestamp
+		}
+	}
+
+	if latestBackup == nil {
+		if allowPartiallyFailed {
+			return unstructured.Unstructured{}, fmt.Errorf("no Completed or PartiallyFailed backup found for schedule %s", scheduleName)
+		}
+		return unstructured.Unstructured{}, fmt.Errorf("no Completed backup found for schedule %s", scheduleName)
+	}
+	return *latestBackup, nil
+}
+
 // ListBackups lists all Velero backups in the specified namespace.
 func ListBackups(dynamicClient dynamic.Interface, namespace string) (*unstructured.UnstructuredList, error) {
 	backups, err := dynamicClient.Resource(backupGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
@@ -105,3 +142,63 @@ func ListBackups(dynamicClient dynamic.Interface, namespace string) (*unstructur
 	return backups, nil
 
 }
+
+// BackupSummary contains the main fields of a Velero backup.
+type BackupSummary struct {
+	Name                string   `json:"name" yaml:"name"`
+	Phase               string   `json:"phase" yaml:"phase"`
+	StartTimestamp      string   `json:"startTimestamp" yaml:"startTimestamp"`
+	CompletionTimestamp string   `json:"completionTimestamp" yaml:"completionTimestamp"`
+	Expiration          string   `json:"expiration" yaml:"expiration"`
+	StorageLocation     string   `json:"storageLocation" yaml:"storageLocation"`
+	Errors              int64    `json:"errors" yaml:"errors"`
+	Warnings            int64    `json:"warnings" yaml:"warnings"`
+	IncludedNamespaces  []string `json:"includedNamespaces" yaml:"includedNamespaces"`
+	ExcludedNamespaces  []string `json:"excludedNamespaces" yaml:"excludedNamespaces"`
+	IncludedResources   []string `json:"includedResources" yaml:"includedResources"`
+	ExcludedResources   []string `json:"excludedResources" yaml:"excludedResources"`
+}
+
+// BackupDetails extends BackupSummary with the volume snapshot and item operation counters of a backup.
+type BackupDetails struct {
+	BackupSummary                 `json:",inline" yaml:",inline"`
+	VolumeSnapshotsAttempted      int64 `json:"volumeSnapshotsAttempted" yaml:"volumeSnapshotsAttempted"`
+	VolumeSnapshotsCompleted      int64 `json:"volumeSnapshotsCompleted" yaml:"volumeSnapshotsCompleted"`
+	CSIVolumeSnapshotsAttempted   int64 `json:"csiVolumeSnapshotsAttempted" yaml:"csiVolumeSnapshotsAttempted"`
+	CSIVolumeSnapshotsCompleted   int64 `json:"csiVolumeSnapshotsCompleted" yaml:"csiVolumeSnapshotsCompleted"`
+	BackupItemOperationsAttempted int64 `json:"backupItemOperationsAttempted" yaml:"backupItemOperationsAttempted"`
+	BackupItemOperationsCompleted int64 `json:"backupItemOperationsCompleted" yaml:"backupItemOperationsCompleted"`
+	BackupItemOperationsFailed    int64 `json:"backupItemOperationsFailed" yaml:"backupItemOperationsFailed"`
+}
+
+// GetBackupSummary extracts the main fields of the given Velero backup.
+func GetBackupSummary(backup unstructured.Unstructured) BackupSummary {
+	return BackupSummary{
+		Name:                backup.GetName(),
+		Phase:               nestedString(backup.Object, "status", "phase"),
+		StartTimestamp:      nestedString(backup.Object, "status", "startTimestamp"),
+		CompletionTimestamp: nestedString(backup.Object, "status", "completionTimestamp"),
+		Expiration:          nestedString(backup.Object, "status", "expiration"),
+		StorageLocation:     nestedString(backup.Object, "spec", "storageLocation"),
+		Errors:              nestedInt64(backup.Object, "status", "errors"),
+		Warnings:            nestedInt64(backup.Object, "status", "warnings"),
+		IncludedNamespaces:  nestedStringSlice(backup.Object, "spec", "includedNamespaces"),
+		ExcludedNamespaces:  nestedStringSlice(backup.Object, "spec", "excludedNamespaces"),
+		IncludedResources:   nestedStringSlice(backup.Object, "spec", "includedResources"),
+		ExcludedResources:   nestedStringSlice(backup.Object, "spec", "excludedResources"),
+	}
+}
+
+// GetBackupDetails extracts the main fields and the volume snapshot and item operation counters of the given Velero backup.
+func GetBackupDetails(backup unstructured.Unstructured) BackupDetails {
+	return BackupDetails{
+		BackupSummary:                 GetBackupSummary(backup),
+		VolumeSnapshotsAttempted:      nestedInt64(backup.Object, "status", "volumeSnapshotsAttempted"),
+		VolumeSnapshotsCompleted:      nestedInt64(backup.Object, "status", "volumeSnapshotsCompleted"),
+		CSIVolumeSnapshotsAttempted:   nestedInt64(backup.Object, "status", "csiVolumeSnapshotsAttempted"),
+		CSIVolumeSnapshotsCompleted:   nestedInt64(backup.Object, "status", "csiVolumeSnapshotsCompleted"),
+		BackupItemOperationsAttempted: nestedInt64(backup.Object, "status", "backupItemOperationsAttempted"),
+		BackupItemOperationsCompleted: nestedInt64(backup.Object, "status", "backupItemOperationsCompleted"),
+		BackupItemOperationsFailed:    nestedInt64(backup.Object, "status", "backupItemOperationsFailed"),
+	}
+}
@@ -0,0 +1,183 @@
+package velero
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	helm "github.com/mittwald/go-helm-client"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	CleanupActionDelete    = "delete"
+	CleanupActionRevert    = "revert"
+	CleanupActionUninstall = "uninstall"
+	helmReleaseKind        = "HelmRelease"
+)
+
+// ManagedResource describes an object created or modified by vresq in the destination cluster, and how to clean it up.
+type ManagedResource struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	RunID     string `json:"runID" yaml:"runID"`
+	Action    string `json:"action" yaml:"action"`
+	gvr       schema.GroupVersionResource
+	// revertedRunIDs lists the runs whose changes are reverted, the latest first.
+	revertedRunIDs []string
+}
+
+// managedKinds lists the kinds of objects vresq creates or modifies, in cleanup order.
+var managedKinds = []struct {
+	kind string
+	gvr  schema.GroupVersionResource
+}{
+	{kind: "BackupStorageLocation", gvr: backupLocationGVR},
+	{kind: "VolumeSnapshotLocation", gvr: volumeSnapshotLocationGVR},
+	{kind: "Secret", gvr: secretGVR},
+	{kind: "ConfigMap", gvr: configmapGVR},
+}
+
+// ListManagedResources lists the objects created or modified by vresq in the given namespace, in cleanup order.
+// When runID is empty, the objects of every run are listed. When helmClient is nil, Helm releases are not listed.
+func ListManagedResources(dynamicClient dynamic.Interface, helmClient helm.Client, namespace string, runID string) ([]ManagedResource, error) {
+	resources := []ManagedResource{}
+	for _, managedKind := range managedKinds {
+		// A modified object carries the ID of the last run which modified it, the other runs are only recorded in its changes
+		objects, err := dynamicClient.Resource(managedKind.gvr).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: RunIDLabel,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not list %s objects in namespace %s: %v", managedKind.kind, namespace, err)
+		}
+		for _, object := range objects.Items {
+			resource := ManagedResource{
+				Kind:      managedKind.kind,
+				Namespace: object.GetNamespace(),
+				Name:      object.GetName(),
+				RunID:     object.GetLabels()[RunIDLabel],
+				gvr:       managedKind.gvr,
+			}
+			changes, err := getDataChanges(&object)
+			if err != nil {
+				return nil, err
+			}
+			// Objects created by vresq are deleted, objects it only modified get the data keys of the runs back
+			if object.GetLabels()[ManagedByLabel] == ManagedByValue && (runID == "" || resource.RunID == runID) {
+				resource.Action = CleanupActionDelete
+			} else if hasDataChanges(changes, runID) {
+				resource.Action = CleanupActionRevert
+				for i := len(changes) - 1; i >= 0; i-- {
+					if runID == "" || changes[i].RunID == runID {
+						resource.revertedRunIDs = append(resource.revertedRunIDs, changes[i].RunID)
+					}
+				}
+				if runID != "" {
+					resource.RunID = runID
+				}
+			} else {
+				continue
+			}
+			resources = append(resources, resource)
+		}
+	}
+
+	if helmClient != nil {
+		releases, err := helmClient.ListDeployedReleases()
+		if err != nil {
+			return nil, fmt.Errorf("could not list Helm releases: %v", err)
+		}
+		for _, release := range releases {
+			if release.Info == nil || !strings.HasPrefix(release.Info.Description, helmDescriptionPrefix) {
+				continue
+			}
+			releaseRunID := strings.TrimPrefix(release.Info.Description, helmDescriptionPrefix)
+			if runID != "" && releaseRunID != runID {
+				continue
+			}
+			resources = append(resources, ManagedResource{
+				Kind:      helmReleaseKind,
+				Namespace: release.Namespace,
+				Name:      release.Name,
+				RunID:     releaseRunID,
+				Action:    CleanupActionUninstall,
+			})
+		}
+	}
+	return resources, nil
+}
+
+// CleanupManagedResource deletes an object created by vresq, restores the previous data of an object it modified,
+// or uninstalls a Helm release it installed.
+func CleanupManagedResource(dynamicClient dynamic.Interface, helmClient helm.Client, resource ManagedResource) error {
+	switch resource.Action {
+	case CleanupActionDelete:
+		if resource.gvr == backupLocationGVR {
+			if err := deleteSyncedBackups(dynamicClient, resource.Namespace, resource.Name); err != nil {
+				return err
+			}
+		}
+		err := dynamicClient.Resource(resource.gvr).Namespace(resource.Namespace).Delete(context.TODO(), resource.Name, metav1.DeleteOptions{})
+		if err != nil {
+			return fmt.Errorf("could not delete %s %s/%s: %v", resource.Kind, resource.Namespace, resource.Name, err)
+		}
+	case CleanupActionRevert:
+		if err := revertModifiedResource(dynamicClient, resource); err != nil {
+			return err
+		}
+	case CleanupActionUninstall:
+		if helmClient == nil {
+			return fmt.Errorf("could not uninstall Helm release %s/%s: no Helm client", resource.Namespace, resource.Name)
+		}
+		if err := helmClient.UninstallReleaseByName(resource.Name); err != nil {
+			return fmt.Errorf("could not uninstall Helm release %s/%s: %v", resource.Namespace, resource.Name, err)
+		}
+	default:
+		return fmt.Errorf("unknown cleanup action %q for %s %s/%s", resource.Action, resource.Kind, resource.Namespace, resource.Name)
+	}
+	return nil
+}
+
+// revertModifiedResource reverts the data keys modified by the runs of a resource, and removes the markers of the runs.
+func revertModifiedResource(dynamicClient dynamic.Interface, resource ManagedResource) error {
+	object, err := dynamicClient.Resource(resource.gvr).Namespace(resource.Namespace).Get(context.TODO(), resource.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get %s %s/%s: %v", resource.Kind, resource.Namespace, resource.Name, err)
+	}
+	for _, revertedRunID := range resource.revertedRunIDs {
+		if err := revertDataChanges(object, revertedRunID); err != nil {
+			return err
+		}
+	}
+
+	_, err = dynamicClient.Resource(resource.gvr).Namespace(resource.Namespace).Update(context.TODO(), object, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not restore %s %s/%s: %v", resource.Kind, resource.Namespace, resource.Name, err)
+	}
+	return nil
+}
+
+// deleteSyncedBackups deletes the Backup objects Velero synchronized from a BackupStorageLocation, like "velero backup-location delete" does.
+// Only the Backup objects are deleted, the backups in the object storage are left untouched.
+func deleteSyncedBackups(dynamicClient dynamic.Interface, namespace string, backupStorageLocationName string) error {
+	backups, err := dynamicClient.Resource(backupGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("velero.io/storage-location=%s", backupStorageLocationName),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list backups synchronized from BackupStorageLocation %s: %v", backupStorageLocationName, err)
+	}
+	for _, backup := range backups.Items {
+		err := dynamicClient.Resource(backupGVR).Namespace(namespace).Delete(context.TODO(), backup.GetName(), metav1.DeleteOptions{})
+		if err != nil {
+			return fmt.Errorf("could not delete backup %s synchronized from BackupStorageLocation %s: %v", backup.GetName(), backupStorageLocationName, err)
+		}
+	}
+	if len(backups.Items) > 0 {
+		log.Printf("Deleted %d backup objects synchronized from BackupStorageLocation %s", len(backups.Items), backupStorageLocationName)
+	}
+	return nil
+}
@@ -5,85 +5,204 @@ import (
 	"fmt"
 	"log"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 )
 
+const (
+	// The restore item action plugins of Velero read their mappings from the ConfigMap carrying these labels, whatever its name.
+	pluginConfigLabel      = "velero.io/plugin-config"
+	restoreItemActionValue = "RestoreItemAction"
+)
+
+// Labels of the ConfigMaps of the Velero restore item action plugins vresq configures.
+const (
+	ChangeStorageClassPlugin    = "velero.io/change-storage-class"
+	ChangePVCNodeSelectorPlugin = "velero.io/change-pvc-node-selector"
+	ChangeImageNamePlugin       = "velero.io/change-image-name"
+)
+
 var (
 	configmapGVR = schema.GroupVersionResource{
 		Group:    "",
 		Version:  "v1",
 		Resource: "configmaps",
 	}
+	// pluginMappingNames names the mappings of each plugin in the logs.
+	pluginMappingNames = map[string]string{
+		ChangeStorageClassPlugin:    "storage class",
+		ChangePVCNodeSelectorPlugin: "node",
+		ChangeImageNamePlugin:       "image name",
+	}
 )
 
-// SetupVeleroConfigmap sets up Velero configuration for mapping old storage classes to the default storage class of the destination cluster.
-func SetupVeleroConfigmap(sourceDynamicClient dynamic.Interface, destinationDynamicClient dynamic.Interface, namespace string) {
-	// Retrieve the list of config maps in the destination namespace
-	configMaps, err := destinationDynamicClient.Resource(configmapGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		log.Fatalf("Error: could not retrieve the list of config maps %v", err)
-	}
+// PluginConfigMapChange records the mappings vresq wrote in the ConfigMap of a Velero plugin during the current run,
+// to be able to revert them once the restore is done.
+type PluginConfigMapChange struct {
+	Plugin    string
+	Namespace string
+	Name      string
+	// Created is set when the ConfigMap did not exist before the current run.
+	Created bool
+	// Keys lists the keys written by the current run.
+	Keys []string
+}
 
-	// List storage classes in the source and destination clusters
-	sourceStorageClasses, err := sourceDynamicClient.Resource(storageClassGVR).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		log.Fatalf("Error: could not list storage classes in the source cluster. %v", err)
+// SetupPluginConfigMap sets up the ConfigMap of the given Velero plugin with the given mappings. Only the mapped keys are written
+// in an existing ConfigMap, the values they replace are reported. An existing ConfigMap of the plugin is used even when its name differs,
+// since Velero expects only one.
+func SetupPluginConfigMap(destinationDynamicClient dynamic.Interface, namespace string, name string, plugin string, mapping map[string]string) (PluginConfigMapChange, error) {
+	change := PluginConfigMapChange{Plugin: plugin, Namespace: namespace, Name: name, Keys: []string{}}
+	mappingName := pluginMappingNames[plugin]
+	if len(mapping) == 0 {
+		log.Printf("No %s to map, the %s ConfigMap is not needed", mappingName, mappingName)
+		return change, nil
 	}
-	destinationStorageClasses, err := destinationDynamicClient.Resource(storageClassGVR).List(context.TODO(), metav1.ListOptions{})
+
+	configMap, err := getPluginConfigMap(destinationDynamicClient, namespace, name, plugin)
 	if err != nil {
-		log.Fatalf("Error: could not list storage classes in the destination cluster. %v", err)
+		return change, err
 	}
 
-	// Find the default storage class of the destination cluster
-	destinationDefaultStorageClass := getDestinationDefaultStorageClass(destinationStorageClasses.Items)
-
-	// Get the names of storage classes in the source cluster
-	oldStorageClasses := getSourceStorageClassNames(sourceStorageClasses.Items)
-
-	// Check if the Velero config map already exists in the destination cluster
-	configMap, found := configMapExists(configMaps.Items)
-
 	// If the config map does not exist, create it
-	if !found {
-		_, err = createStorageClassConfigMap(destinationDynamicClient, configMapName, oldStorageClasses, destinationDefaultStorageClass, namespace)
-		if err != nil {
-			log.Fatal(err)
+	if configMap == nil {
+		if _, err := createPluginConfigMap(destinationDynamicClient, name, plugin, mapping, namespace); err != nil {
+			return change, fmt.Errorf("could not create ConfigMap %s in namespace %s: %v", name, namespace, err)
 		}
-	} else {
-		// Update the existing config map with the new storage class mappings
-		data, _, _ := unstructured.NestedStringMap(configMap.Object, "data")
-		for _, oldStorageClass := range oldStorageClasses {
-			data[oldStorageClass] = destinationDefaultStorageClass
+		change.Created = true
+		change.Keys = sortedStringMapKeys(mapping)
+		return change, nil
+	}
+	if configMap.GetName() != name {
+		log.Printf("ConfigMap %s already configures the Velero %s plugin, it is used instead of %s", configMap.GetName(), plugin, name)
+		change.Name = configMap.GetName()
+	}
+
+	// Update the existing config map with the new mappings only
+	data, _, _ := unstructured.NestedStringMap(configMap.Object, "data")
+	if data == nil {
+		data = map[string]string{}
+	}
+	for _, key := range sortedStringMapKeys(mapping) {
+		value := mapping[key]
+		previousValue, found := data[key]
+		if found && previousValue == value {
+			continue
 		}
-		configMap.Object["data"] = data
-		_, err = destinationDynamicClient.Resource(configmapGVR).Namespace(namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{})
-		if err != nil {
-			panic(err.Error())
+		if found {
+			log.Printf("Warning: ConfigMap %s maps %s %s to %s, it is mapped to %s for this restore", change.Name, mappingName, key, previousValue, value)
 		}
-		fmt.Printf("ConfigMap %s in namespace %s updated successfully\n", configMapName, namespace)
+		change.Keys = append(change.Keys, key)
+		data[key] = value
+	}
+	if len(change.Keys) == 0 && hasPluginLabels(configMap, plugin) {
+		log.Printf("ConfigMap %s in namespace %s already holds the %s mappings", change.Name, namespace, mappingName)
+		return change, nil
+	}
+
+	// Record the values of the mapped keys before they are modified, to be able to restore them
+	if err := markModified(configMap, change.Keys); err != nil {
+		return change, err
+	}
+	if err := unstructured.SetNestedStringMap(configMap.Object, data, "data"); err != nil {
+		return change, err
+	}
+	labels := configMap.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[pluginConfigLabel] = ""
+	labels[plugin] = restoreItemActionValue
+	configMap.SetLabels(labels)
+	if IsDryRun() {
+		recordObject("ConfigMap", namespace, change.Name, ObjectActionUpdated)
+		return change, renderObject(fmt.Sprintf("ConfigMap %s/%s would be updated", namespace, change.Name), configMap.Object)
 	}
+	_, err = destinationDynamicClient.Resource(configmapGVR).Namespace(namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{})
+	if err != nil {
+		return change, fmt.Errorf("could not update ConfigMap %s in namespace %s: %v", change.Name, namespace, err)
+	}
+	recordObject("ConfigMap", namespace, change.Name, ObjectActionUpdated)
+	log.Printf("ConfigMap %s in namespace %s updated successfully", change.Name, namespace)
+	return change, nil
 }
 
-// configMapExists checks if the Velero config map already exists in the destination cluster.
-func configMapExists(configMaps []unstructured.Unstructured) (*unstructured.Unstructured, bool) {
-	for _, configMap := range configMaps {
-		if configMap.GetName() == configMapName {
-			return &configMap, true
+// RevertPluginConfigMap reverts the mappings written in the ConfigMap of a Velero plugin during the current run: the ConfigMap is deleted
+// when the run created it, otherwise the mapped keys get their previous value back, or are removed when they had none.
+func RevertPluginConfigMap(dynamicClient dynamic.Interface, change PluginConfigMapChange) error {
+	if len(change.Keys) == 0 {
+		return nil
+	}
+	if change.Created {
+		err := dynamicClient.Resource(configmapGVR).Namespace(change.Namespace).Delete(context.TODO(), change.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("could not delete ConfigMap %s in namespace %s: %v", change.Name, change.Namespace, err)
 		}
+		log.Printf("ConfigMap %s in namespace %s deleted", change.Name, change.Namespace)
+		return nil
+	}
+
+	configMap, err := dynamicClient.Resource(configmapGVR).Namespace(change.Namespace).Get(context.TODO(), change.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get ConfigMap %s in namespace %s: %v", change.Name, change.Namespace, err)
+	}
+	if err := revertDataChanges(configMap, runID); err != nil {
+		return err
+	}
+
+	_, err = dynamicClient.Resource(configmapGVR).Namespace(change.Namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not revert ConfigMap %s in namespace %s: %v", change.Name, change.Namespace, err)
+	}
+	log.Printf("ConfigMap %s in namespace %s reverted", change.Name, change.Namespace)
+	return nil
+}
+
+// getPluginConfigMap returns the ConfigMap of the given Velero plugin in the given namespace: the one carrying the plugin labels,
+// or the one with the given name. It returns nil when there is none.
+func getPluginConfigMap(dynamicClient dynamic.Interface, namespace string, name string, plugin string) (*unstructured.Unstructured, error) {
+	configMaps, err := dynamicClient.Resource(configmapGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s,%s=%s", pluginConfigLabel, plugin, restoreItemActionValue),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list ConfigMaps in namespace %s: %v", namespace, err)
 	}
-	return nil, false
+	if len(configMaps.Items) > 1 {
+		return nil, fmt.Errorf("%d ConfigMaps configure the Velero %s plugin in namespace %s, Velero expects only one", len(configMaps.Items), plugin, namespace)
+	}
+	if len(configMaps.Items) == 1 {
+		return &configMaps.Items[0], nil
+	}
+
+	configMap, err := dynamicClient.Resource(configmapGVR).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get ConfigMap %s in namespace %s: %v", name, namespace, err)
+	}
+	return configMap, nil
+}
+
+// hasPluginLabels checks whether a ConfigMap carries the labels of the configuration of the given Velero plugin.
+func hasPluginLabels(configMap *unstructured.Unstructured, plugin string) bool {
+	labels := configMap.GetLabels()
+	_, pluginConfig := labels[pluginConfigLabel]
+	return pluginConfig && labels[plugin] == restoreItemActionValue
 }
 
-// createStorageClassConfigMap creates a new Velero config map with the specified storage class mappings.
-func createStorageClassConfigMap(dynamicClient dynamic.Interface, configMapName string, oldStorageClasses []string, newStorageClass string, namespace string) (map[string]string, error) {
+// createPluginConfigMap creates a new ConfigMap of the given Velero plugin with the specified mappings.
+func createPluginConfigMap(dynamicClient dynamic.Interface, configMapName string, plugin string, mapping map[string]string, namespace string) (map[string]string, error) {
 	data := map[string]string{}
+	objectData := map[string]interface{}{}
 
-	// Populate ConfigMap data with storage class mappings
-	for _, oldStorageClass := range oldStorageClasses {
-		data[oldStorageClass] = newStorageClass
+	// Populate ConfigMap data with the mappings
+	for key, value := range mapping {
+		data[key] = value
+		objectData[key] = value
 	}
 
 	// Create the ConfigMap object
@@ -95,11 +214,11 @@ func createStorageClassConfigMap(dynamicClient dynamic.Interface, configMapName
 				"name":      configMapName,
 				"namespace": namespace,
 				"labels": map[string]interface{}{
-					"velero.io/plugin-config":        "",
-					"velero.io/change-storage-class": "RestoreItemAction",
+					pluginConfigLabel: "",
+					plugin:            restoreItemActionValue,
 				},
 			},
-			"data": data,
+			"data": objectData,
 		},
 	}
 
@@ -107,8 +226,8 @@ func createStorageClassConfigMap(dynamicClient dynamic.Interface, configMapName
 	err := createResource(dynamicClient, namespace, &configMap, "configmaps")
 	if err != nil {
 		return nil, err
-	} else {
-		log.Println("Successfully configured Velero to map all storage classes in the old cluster to the default storage class of the destination.")
+	} else if !IsDryRun() {
+		log.Printf("Successfully configured the Velero %s plugin with %d %s mappings.", plugin, len(data), pluginMappingNames[plugin])
 	}
 
 	return data, nil
@@ -0,0 +1,267 @@
+package velero
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	helm "github.com/mittwald/go-helm-client"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+var (
+	customResourceDefinitionGVR = schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	}
+	// veleroCRDs lists the Velero custom resources vresq relies on.
+	veleroCRDs = []string{"backups", "restores", "schedules", "backupstoragelocations", "volumesnapshotlocations", "downloadrequests"}
+)
+
+// CheckResult is the outcome of one preflight check, with a remediation hint when it did not pass.
+type CheckResult struct {
+	Cluster string `json:"cluster" yaml:"cluster"`
+	Name    string `json:"name" yaml:"name"`
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+	Hint    string `json:"hint,omitempty" yaml:"hint,omitempty"`
+}
+
+// CheckVeleroCRDs checks that the Velero custom resource definitions are served in the cluster.
+// missingStatus is the status reported when they are not, since Velero can be cloned in the destination cluster.
+func CheckVeleroCRDs(dynamicClient dynamic.Interface, cluster string, missingStatus string) CheckResult {
+	result := CheckResult{Cluster: cluster, Name: "Velero CRDs"}
+	missing := []string{}
+	for _, resource := range veleroCRDs {
+		crdName := fmt.Sprintf("%s.%s", resource, veleroApiGroup)
+		crd, err := dynamicClient.Resource(customResourceDefinitionGVR).Get(context.TODO(), crdName, metav1.GetOptions{})
+		if err != nil || !isVersionServed(crd, apiVersion) {
+			missing = append(missing, crdName)
+		}
+	}
+	if len(missing) > 0 {
+		result.Status = missingStatus
+		result.Message = fmt.Sprintf("not served: %s", strings.Join(missing, ", "))
+		result.Hint = "install Velero in this cluster, or run vresq with --clone-velero to clone it from the source cluster"
+		return result
+	}
+	result.Status = CheckPass
+	result.Message = fmt.Sprintf("%d CRDs served in version %s", len(veleroCRDs), apiVersion)
+	return result
+}
+
+// isVersionServed checks whether a custom resource definition serves the given version.
+func isVersionServed(crd *unstructured.Unstructured, version string) bool {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		versionMap, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if versionMap["name"] == version && versionMap["served"] == true {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckVeleroServer checks that a Velero server pod is running in the cluster, and returns it when found.
+// missingStatus is the status reported when there is none, since Velero can be cloned in the destination cluster.
+func CheckVeleroServer(dynamicClient dynamic.Interface, cluster string, missingStatus string) (CheckResult, *unstructured.Unstructured) {
+	result := CheckResult{Cluster: cluster, Name: "Velero server"}
+	veleroPod, err := GetVeleroPod(dynamicClient)
+	if err != nil {
+		result.Status = missingStatus
+		result.Message = err.Error()
+		result.Hint = "install Velero in this cluster, or run vresq with --clone-velero to clone it from the source cluster"
+		return result, nil
+	}
+	phase := nestedString(veleroPod.Object, "status", "phase")
+	if phase != "Running" {
+		result.Status = CheckFail
+		result.Message = fmt.Sprintf("pod %s/%s is %s", veleroPod.GetNamespace(), veleroPod.GetName(), phase)
+		result.Hint = fmt.Sprintf("check the pod events and logs: kubectl -n %s describe pod %s", veleroPod.GetNamespace(), veleroPod.GetName())
+		return result, &veleroPod
+	}
+	result.Status = CheckPass
+	result.Message = fmt.Sprintf("pod %s/%s is Running", veleroPod.GetNamespace(), veleroPod.GetName())
+	return result, &veleroPod
+}
+
+// CheckBackupStorageLocations checks that the BackupStorageLocations are Available.
+// When backupName is given, only the location of that backup is checked.
+func CheckBackupStorageLocations(dynamicClient dynamic.Interface, cluster string, namespace string, backupName string) []CheckResult {
+	locations := []unstructured.Unstructured{}
+	if backupName != "" {
+		backup, err := GetBackup(dynamicClient, namespace, backupName)
+		if err != nil {
+			return []CheckResult{{
+				Cluster: cluster,
+				Name:    "Backup",
+				Status:  CheckFail,
+				Message: fmt.Sprintf("could not get backup %s: %v", backupName, err),
+				Hint:    "list the available backups with: vresq backups list",
+			}}
+		}
+		locationName := nestedString(backup.Object, "spec", "storageLocation")
+		location, err := getBackupStorageLocation(dynamicClient, namespace, locationName)
+		if err != nil {
+			return []CheckResult{{
+				Cluster: cluster,
+				Name:    "BackupStorageLocation",
+				Status:  CheckFail,
+				Message: fmt.Sprintf("could not get BackupStorageLocation %s of backup %s: %v", locationName, backupName, err),
+				Hint:    "the backup can only be restored from the location it was stored in",
+			}}
+		}
+		locations = append(locations, location)
+	} else {
+		list, err := listBackupStorageLocations(dynamicClient, namespace)
+		if err != nil {
+			return []CheckResult{{
+				Cluster: cluster,
+				Name:    "BackupStorageLocation",
+				Status:  CheckFail,
+				Message: fmt.Sprintf("could not list BackupStorageLocations in namespace %s: %v", namespace, err),
+			}}
+		}
+		locations = list.Items
+	}
+	if len(locations) == 0 {
+		return []CheckResult{{
+			Cluster: cluster,
+			Name:    "BackupStorageLocation",
+			Status:  CheckFail,
+			Message: fmt.Sprintf("no BackupStorageLocation in namespace %s", namespace),
+			Hint:    "create a BackupStorageLocation pointing to the bucket of the backups",
+		}}
+	}
+
+	results := []CheckResult{}
+	for _, location := range locations {
+		result := CheckResult{Cluster: cluster, Name: fmt.Sprintf("BackupStorageLocation %s", location.GetName())}
+		phase := nestedString(location.Object, "status", "phase")
+		if phase == "Available" {
+			result.Status = CheckPass
+			result.Message = "Available"
+		} else {
+			result.Status = CheckFail
+			result.Message = fmt.Sprintf("phase is %s", valueOrUnknown(phase, nestedString(location.Object, "status", "message")))
+			result.Hint = fmt.Sprintf("check the bucket, the credentials and the Velero logs: kubectl -n %s logs deploy/velero", namespace)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// CheckDefaultStorageClass checks that the cluster has a default StorageClass to map the source storage classes to.
+// A missing default StorageClass is reported with the given status, a warning when the storage classes are mapped explicitly.
+func CheckDefaultStorageClass(dynamicClient dynamic.Interface, cluster string, missingStatus string) CheckResult {
+	result := CheckResult{Cluster: cluster, Name: "Default StorageClass"}
+	storageClasses, err := dynamicClient.Resource(storageClassGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		result.Status = CheckFail
+		result.Message = fmt.Sprintf("could not list storage classes: %v", err)
+		return result
+	}
+	defaultStorageClass := getDestinationDefaultStorageClass(storageClasses.Items)
+	if defaultStorageClass == "" {
+		result.Status = missingStatus
+		result.Message = "no default StorageClass"
+		result.Hint = "mark a StorageClass as default: kubectl annotate storageclass <name> storageclass.kubernetes.io/is-default-class=true, or map every storage class with --storage-class-mapping"
+		return result
+	}
+	result.Status = CheckPass
+	result.Message = defaultStorageClass
+	return result
+}
+
+// CheckVeleroCredentials checks that the Velero server pod mounts the 'cloud-credentials' volume and that its Secret exists.
+func CheckVeleroCredentials(dynamicClient dynamic.Interface, cluster string, veleroPod *unstructured.Unstructured) []CheckResult {
+	volumeResult := CheckResult{Cluster: cluster, Name: "cloud-credentials volume"}
+	secretName, err := getVeleroPodSecretName(veleroPod)
+	if err != nil {
+		volumeResult.Status = CheckWarn
+		volumeResult.Message = err.Error()
+		volumeResult.Hint = "without global credentials, every BackupStorageLocation must reference a Secret in spec.credential"
+		return []CheckResult{volumeResult}
+	}
+	volumeResult.Status = CheckPass
+	volumeResult.Message = fmt.Sprintf("mounted from Secret %s", secretName)
+
+	secretResult := CheckResult{Cluster: cluster, Name: "Credentials Secret"}
+	if _, err := GetSecret(dynamicClient, veleroPod.GetNamespace(), secretName); err != nil {
+		secretResult.Status = CheckFail
+		secretResult.Message = fmt.Sprintf("could not read Secret %s/%s: %v", veleroPod.GetNamespace(), secretName, err)
+		secretResult.Hint = "vresq clones this Secret to access the bucket from the destination cluster, check that it exists and that you can read it"
+	} else {
+		secretResult.Status = CheckPass
+		secretResult.Message = fmt.Sprintf("Secret %s/%s found", veleroPod.GetNamespace(), secretName)
+	}
+	return []CheckResult{volumeResult, secretResult}
+}
+
+// CheckHelmRelease checks that the Velero Helm release can be discovered, to be cloned in the destination cluster.
+// When releaseName is empty, the release is searched by its chart name.
+func CheckHelmRelease(helmClient helm.Client, cluster string, releaseName string) CheckResult {
+	result := CheckResult{Cluster: cluster, Name: "Velero Helm release"}
+	hint := "the Helm release is only needed to clone Velero in the destination cluster, specify it with --source-velero-helm-release-name"
+	if releaseName != "" {
+		if _, err := helmClient.GetRelease(releaseName); err != nil {
+			result.Status = CheckWarn
+			result.Message = fmt.Sprintf("could not get release %s: %v", releaseName, err)
+			result.Hint = hint
+			return result
+		}
+		result.Status = CheckPass
+		result.Message = releaseName
+		return result
+	}
+
+	releases, err := findHelmReleasesByShortName("velero", helmClient)
+	if err != nil {
+		result.Status = CheckWarn
+		result.Message = err.Error()
+		result.Hint = hint
+		return result
+	}
+	switch len(releases) {
+	case 0:
+		result.Status = CheckWarn
+		result.Message = "no release of a velero chart found"
+		result.Hint = hint
+	case 1:
+		result.Status = CheckPass
+		result.Message = releases[0].Name
+	default:
+		names := []string{}
+		for _, release := range releases {
+			names = append(names, release.Name)
+		}
+		result.Status = CheckWarn
+		result.Message = fmt.Sprintf("multiple releases of a velero chart found: %s", strings.Join(names, ", "))
+		result.Hint = hint
+	}
+	return result
+}
+
+// valueOrUnknown formats a phase and its optional message, or "Unknown" when the phase is empty.
+func valueOrUnknown(phase string, message string) string {
+	if phase == "" {
+		phase = "Unknown"
+	}
+	if message != "" {
+		return fmt.Sprintf("%s (%s)", phase, message)
+	}
+	return phase
+}
@@ -0,0 +1,262 @@
+package velero
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	DownloadTargetKindRestoreLog     = "RestoreLog"
+	DownloadTargetKindRestoreResults = "RestoreResults"
+	DownloadTargetKindBackupContents = "BackupContents"
+)
+
+var (
+	downloadRequestGVR = schema.GroupVersionResource{
+		Group:    veleroApiGroup,
+		Version:  apiVersion,
+		Resource: "downloadrequests",
+	}
+)
+
+// DownloadOptions configures how the signed URLs returned by Velero are fetched.
+type DownloadOptions struct {
+	// InsecureSkipTLSVerify disables the verification of the object storage certificate.
+	InsecureSkipTLSVerify bool
+	// CACertFile is the path of a PEM bundle used to verify the object storage certificate.
+	CACertFile string
+	// Timeout bounds both the wait for the signed URL and the HTTP download.
+	Timeout time.Duration
+}
+
+// RestoreResult holds the messages of one type (warnings or errors) reported by a restore.
+type RestoreResult struct {
+	Velero     []string            `json:"velero,omitempty" yaml:"velero,omitempty"`
+	Cluster    []string            `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	Namespaces map[string][]string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+// RestoreResults holds the warnings and errors reported by a restore, as stored by Velero in the object storage.
+type RestoreResults struct {
+	Warnings RestoreResult `json:"warnings" yaml:"warnings"`
+	Errors   RestoreResult `json:"errors" yaml:"errors"`
+}
+
+// GetRestoreResults downloads and parses the warnings and errors of a restore through a DownloadRequest.
+func GetRestoreResults(dynamicClient dynamic.Interface, namespace string, restoreName string, options DownloadOptions) (RestoreResults, error) {
+	var results RestoreResults
+	reader, err := Download(dynamicClient, namespace, DownloadTargetKindRestoreResults, restoreName, options)
+	if err != nil {
+		return results, err
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(&results); err != nil {
+		return results, fmt.Errorf("could not decode results of restore %s: %v", restoreName, err)
+	}
+	return results, nil
+}
+
+// SaveRestoreLogs downloads the logs of a restore through a DownloadRequest and writes them to the given file.
+func SaveRestoreLogs(dynamicClient dynamic.Interface, namespace string, restoreName string, options DownloadOptions, path string) error {
+	reader, err := Download(dynamicClient, namespace, DownloadTargetKindRestoreLog, restoreName, options)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("could not write logs of restore %s to %s: %v", restoreName, path, err)
+	}
+	return nil
+}
+
+// Download creates a Velero DownloadRequest for the given target, waits for its signed URL and returns the decompressed content.
+// The caller is responsible for closing the returned reader.
+func Download(dynamicClient dynamic.Interface, namespace string, kind string, name string, options DownloadOptions) (io.ReadCloser, error) {
+	if options.Timeout == 0 {
+		options.Timeout = time.Minute
+	}
+	downloadURL, err := getDownloadURL(dynamicClient, namespace, kind, name, options.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := newDownloadHTTPClient(options)
+	if err != nil {
+		return nil, err
+	}
+	response, err := httpClient.Get(downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not download %s of %s: %v", kind, name, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		return nil, fmt.Errorf("could not download %s of %s, request failed with status %s: %s", kind, name, response.Status, string(body))
+	}
+
+	// Velero stores logs and results gzipped in the object storage
+	gzipReader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		response.Body.Close()
+		return nil, fmt.Errorf("could not decompress %s of %s: %v", kind, name, err)
+	}
+	return &gzipReadCloser{Reader: gzipReader, body: response.Body}, nil
+}
+
+// getDownloadURL creates a DownloadRequest and waits until Velero has processed it, then deletes it and returns the signed URL.
+func getDownloadURL(dynamicClient dynamic.Interface, namespace string, kind string, name string, timeout time.Duration) (string, error) {
+	downloadRequest := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", veleroApiGroup, apiVersion),
+			"kind":       "DownloadRequest",
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("%s-%s", name, time.Now().Format("20060102150405")),
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"target": map[string]interface{}{
+					"kind": kind,
+					"name": name,
+				},
+			},
+		},
+	}
+	created, err := dynamicClient.Resource(downloadRequestGVR).Namespace(namespace).Create(context.TODO(), &downloadRequest, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not create DownloadRequest for %s of %s: %v", kind, name, err)
+	}
+	defer dynamicClient.Resource(downloadRequestGVR).Namespace(namespace).Delete(context.TODO(), created.GetName(), metav1.DeleteOptions{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	pollInterval := time.Second
+	for {
+		current, err := dynamicClient.Resource(downloadRequestGVR).Namespace(namespace).Get(ctx, created.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("could not get DownloadRequest %s: %v", created.GetName(), err)
+		}
+		if nestedString(current.Object, "status", "phase") == "Processed" {
+			downloadURL := nestedString(current.Object, "status", "downloadURL")
+			if downloadURL == "" {
+				return "", fmt.Errorf("velero did not return any download URL for %s of %s, it may not exist in the object storage", kind, name)
+			}
+			return downloadURL, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("timeout reached (%v) waiting for velero to process DownloadRequest %s", timeout, created.GetName())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// newDownloadHTTPClient builds the HTTP client used to fetch signed URLs from an S3-compatible object storage.
+func newDownloadHTTPClient(options DownloadOptions) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipTLSVerify,
+	}
+	if options.CACertFile != "" {
+		caCert, err := os.ReadFile(options.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA certificate %s: %v", options.CACertFile, err)
+		}
+		certPool, err := x509.SystemCertPool()
+		if err != nil {
+			certPool = x509.NewCertPool()
+		}
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("could not parse CA certificate %s", options.CACertFile)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	// The content is gzipped by Velero itself, it must not be transparently decompressed
+	transport.DisableCompression = true
+	return &http.Client{
+		Transport: transport,
+		Timeout:   options.Timeout,
+	}, nil
+}
+
+// gzipReadCloser closes both the gzip reader and the underlying HTTP response body.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close closes the gzip reader and the HTTP response body.
+func (r *gzipReadCloser) Close() error {
+	r.Reader.Close()
+	return r.body.Close()
+}
+
+// WriteRestoreResults writes a per-namespace table of the warnings and errors of a restore, followed by their messages.
+func WriteRestoreResults(w io.Writer, results RestoreResults) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "SCOPE\tWARNINGS\tERRORS")
+	fmt.Fprintf(tw, "Velero\t%d\t%d\n", len(results.Warnings.Velero), len(results.Errors.Velero))
+	fmt.Fprintf(tw, "Cluster\t%d\t%d\n", len(results.Warnings.Cluster), len(results.Errors.Cluster))
+	for _, namespace := range resultNamespaces(results) {
+		fmt.Fprintf(tw, "Namespace %s\t%d\t%d\n", namespace, len(results.Warnings.Namespaces[namespace]), len(results.Errors.Namespaces[namespace]))
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "SCOPE\tTYPE\tMESSAGE")
+	writeResultMessages(tw, "Velero", results.Warnings.Velero, results.Errors.Velero)
+	writeResultMessages(tw, "Cluster", results.Warnings.Cluster, results.Errors.Cluster)
+	for _, namespace := range resultNamespaces(results) {
+		writeResultMessages(tw, fmt.Sprintf("Namespace %s", namespace), results.Warnings.Namespaces[namespace], results.Errors.Namespaces[namespace])
+	}
+	return tw.Flush()
+}
+
+// writeResultMessages writes one table row per warning and error message of a scope.
+func writeResultMessages(w io.Writer, scope string, warnings []string, errors []string) {
+	for _, message := range warnings {
+		fmt.Fprintf(w, "%s\twarning\t%s\n", scope, message)
+	}
+	for _, message := range errors {
+		fmt.Fprintf(w, "%s\terror\t%s\n", scope, message)
+	}
+}
+
+// resultNamespaces returns the sorted names of the namespaces that have warnings or errors.
+func resultNamespaces(results RestoreResults) []string {
+	namespaces := []string{}
+	seen := map[string]bool{}
+	for _, result := range []RestoreResult{results.Warnings, results.Errors} {
+		for namespace := range result.Namespaces {
+			if !seen[namespace] {
+				seen[namespace] = true
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
@@ -0,0 +1,41 @@
+package velero
+
+import (
+	"fmt"
+	"io"
+)
+
+const redactedValue = "<redacted>"
+
+// dryRunWriter receives the manifests of the objects that would be written when dry-run mode is enabled.
+var dryRunWriter io.Writer
+
+// SetDryRun enables dry-run mode: objects are rendered as YAML to w instead of being applied.
+// A nil writer disables dry-run mode.
+func SetDryRun(w io.Writer) {
+	dryRunWriter = w
+}
+
+// IsDryRun reports whether dry-run mode is enabled.
+func IsDryRun() bool {
+	return dryRunWriter != nil
+}
+
+// renderObject writes the YAML manifest of an object that would be applied, preceded by a comment describing the action.
+func renderObject(action string, object map[string]interface{}) error {
+	objectYAML, err := mapToYAML(object)
+	if err != nil {
+		return fmt.Errorf("could not render object as YAML: %v", err)
+	}
+	_, err = fmt.Fprintf(dryRunWriter, "---\n# %s\n%s", action, objectYAML)
+	return err
+}
+
+// redactData returns a copy of the data where every value is replaced by a placeholder.
+func redactData(data map[string]string) map[string]interface{} {
+	redacted := map[string]interface{}{}
+	for key := range data {
+		redacted[key] = redactedValue
+	}
+	return redacted
+}
@@ -0,0 +1,95 @@
+package velero
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	common "vresq/pkg/common"
+)
+
+// VeleroRestoreCreateArgs returns the arguments of the "velero restore create" command equivalent to the Restore built by BuildVeleroRestore.
+func VeleroRestoreCreateArgs(namespace string, name string, options common.VeleroRestoreOptions) []string {
+	args := []string{"velero", "restore", "create", name, "--namespace", namespace}
+
+	// Velero rejects restores with both a backup and a schedule, the backup resolved from a schedule takes precedence
+	if options.BackupName != "" {
+		args = append(args, "--from-backup", options.BackupName)
+	} else if options.ScheduleName != "" {
+		args = append(args, "--from-schedule", options.ScheduleName)
+	}
+	if len(options.IncludedNamespaces) > 0 {
+		args = append(args, "--include-namespaces", strings.Join(options.IncludedNamespaces, ","))
+	}
+	if len(options.ExcludedNamespaces) > 0 {
+		args = append(args, "--exclude-namespaces", strings.Join(options.ExcludedNamespaces, ","))
+	}
+	if len(options.IncludedResources) > 0 {
+		args = append(args, "--include-resources", strings.Join(options.IncludedResources, ","))
+	}
+	if len(options.ExcludedResources) > 0 {
+		args = append(args, "--exclude-resources", strings.Join(options.ExcludedResources, ","))
+	}
+	args = append(args, fmt.Sprintf("--include-cluster-resources=%t", options.IncludeClusterResources))
+	if len(options.NamespaceMapping) > 0 {
+		args = append(args, "--namespace-mappings", joinPairs(options.NamespaceMapping, ":", ","))
+	}
+	if strings.TrimSpace(options.LabelSelector) != "" {
+		args = append(args, "--selector", options.LabelSelector)
+	}
+	if orTerms := OrLabelSelectorTerms(options); len(orTerms) > 0 {
+		args = append(args, "--or-selector", strings.Join(orTerms, orSelectorSeparator))
+	}
+	if options.ExistingResourcePolicy != "" {
+		args = append(args, "--existing-resource-policy", options.ExistingResourcePolicy)
+	}
+	args = append(args, fmt.Sprintf("--restore-volumes=%t", options.RestorePVs))
+	args = append(args, fmt.Sprintf("--preserve-nodeports=%t", options.PreserveNodePorts))
+	if HasResourceModifiers(options) {
+		args = append(args, "--resource-modifier-configmap", ResourceModifiersConfigMapName(name))
+	}
+	if options.ItemOperationTimeout > 0 {
+		args = append(args, "--item-operation-timeout", options.ItemOperationTimeout.String())
+	}
+	return args
+}
+
+// FormatCommand formats command arguments as a shell command, one flag per line, quoting the arguments when needed.
+func FormatCommand(args []string) string {
+	var builder strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			// Start a new line before every flag, after the command and its positional arguments
+			if strings.HasPrefix(arg, "--") {
+				builder.WriteString(" \\\n  ")
+			} else {
+				builder.WriteString(" ")
+			}
+		}
+		builder.WriteString(shellQuote(arg))
+	}
+	return builder.String()
+}
+
+// joinPairs joins the key and value pairs of a map, sorted by key.
+func joinPairs(values map[string]string, separator string, pairSeparator string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		pairs = append(pairs, key+separator+values[key])
+	}
+	return strings.Join(pairs, pairSeparator)
+}
+
+// shellQuote quotes an argument with single quotes when it contains characters interpreted by the shell.
+func shellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.,:=/@+%", r))
+	}) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
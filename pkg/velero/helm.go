@@ -15,42 +15,100 @@ import (
 // getHelmReleaseByShortName retrieves a Helm release by its short name.
 // It returns the release and a boolean indicating if the release is found.
 func getHelmReleaseByShortName(shortName string, helmClient helm.Client) (release.Release, bool) {
+	filteredReleases, err := findHelmReleasesByShortName(shortName, helmClient)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+		return release.Release{}, false
+	}
+
+	// Handle filtered releases
+	if len(filteredReleases) == 0 {
+		return release.Release{}, false
+	}
+	if len(filteredReleases) > 1 {
+		log.Println("Warning: Found multiple Helm releases in the cluster with \"velero\" in name:")
+		for _, release := range filteredReleases {
+			log.Println(release.Name)
+		}
+		return release.Release{}, false
+	}
+	return filteredReleases[0], true
+}
+
+// findHelmReleasesByShortName lists the deployed Helm releases whose chart name contains the short name.
+// It returns an error if the releases cannot be listed or if no release is deployed at all.
+func findHelmReleasesByShortName(shortName string, helmClient helm.Client) ([]release.Release, error) {
 	// List deployed Helm releases
 	releases, err := helmClient.ListDeployedReleases()
 	if err != nil {
-		log.Fatalf("Error: Could not list deployed Helm releases: %v", err)
-		return release.Release{}, false
+		return nil, fmt.Errorf("could not list deployed Helm releases: %v", err)
 	}
 	if len(releases) == 0 {
-		log.Fatal("Error: No deployed Helm releases found in the source cluster.")
-		return release.Release{}, false
+		return nil, fmt.Errorf("no deployed Helm releases found in the source cluster")
 	}
 
 	// Filter releases by shortName
 	filteredReleases := []release.Release{}
 	for _, release := range releases {
-		fmt.Printf("Found release: %s\n", release.Name)
+		log.Printf("Found release: %s\n", release.Name)
 		if strings.Contains(release.Chart.Name(), shortName) {
 			filteredReleases = append(filteredReleases, *release)
 		}
 	}
+	return filteredReleases, nil
+}
 
-	// Handle filtered releases
-	if len(filteredReleases) == 0 {
-		return release.Release{}, false
-	}
-	if len(filteredReleases) > 1 {
-		log.Println("Warning: Found multiple Helm releases in the cluster with \"velero\" in name:")
-		for _, release := range filteredReleases {
-			log.Println(release.Name)
+// redactHelmValues returns a deep copy of Helm values where the values of the secretContents and existingSecret maps,
+// and of credentials.extraEnvVars, are replaced by a placeholder.
+func redactHelmValues(values map[string]interface{}, parentKey string) map[string]interface{} {
+	redacted := map[string]interface{}{}
+	for key, value := range values {
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			if key == "secretContents" || key == "existingSecret" || (parentKey == "credentials" && key == "extraEnvVars") {
+				redacted[key] = redactValues(nested)
+			} else {
+				redacted[key] = redactHelmValues(nested, key)
+			}
+		case []interface{}:
+			items := make([]interface{}, len(nested))
+			for i, item := range nested {
+				if itemValues, isMap := item.(map[string]interface{}); isMap {
+					items[i] = redactHelmValues(itemValues, key)
+				} else {
+					items[i] = item
+				}
+			}
+			redacted[key] = items
+		default:
+			redacted[key] = value
 		}
-		return release.Release{}, false
 	}
-	return filteredReleases[0], true
+	return redacted
+}
+
+// redactValues returns a copy of a map where every value is replaced by a placeholder.
+func redactValues(values map[string]interface{}) map[string]interface{} {
+	redacted := map[string]interface{}{}
+	for key := range values {
+		redacted[key] = redactedValue
+	}
+	return redacted
 }
 
 // cloneVeleroHelmChart clones the Velero Helm chart to the destination Kubernetes cluster.
+// In dry-run mode, only the values that would be installed are rendered.
 func cloneVeleroHelmChart(destinationHelmClient helm.Client, destinationHelmValues map[string]interface{}, sourceVeleroRelease release.Release, destinationReleaseNamespace string) {
+	if IsDryRun() {
+		action := fmt.Sprintf("Helm chart velero/%s %s would be installed in namespace %s with values:", sourceVeleroRelease.Chart.Name(), sourceVeleroRelease.Chart.Metadata.Version, destinationReleaseNamespace)
+		// The chart commonly holds the cloud credentials of Velero in its values
+		if err := renderObject(action, redactHelmValues(destinationHelmValues, "")); err != nil {
+			log.Fatalf("Error: Could not render Velero Helm values: %v", err)
+		}
+		recordObject(helmReleaseKind, destinationReleaseNamespace, sourceVeleroRelease.Chart.Name(), ObjectActionInstalled)
+		return
+	}
+
 	// Define the chart repository
 	chartRepo := repo.Entry{
 		Name:                  "velero",
@@ -80,10 +138,13 @@ func cloneVeleroHelmChart(destinationHelmClient helm.Client, destinationHelmValu
 		WaitForJobs:     true,
 		Timeout:         15 * time.Minute,
 		ValuesYaml:      destinationRealeaseValuesYAML,
+		Description:     helmReleaseDescription(),
 	}
 
 	// Install or upgrade the Helm chart on the destination Kubernetes cluster
-	if _, err := destinationHelmClient.InstallOrUpgradeChart(context.Background(), &destinationChartSpec, &helm.GenericHelmOptions{}); err != nil {
+	destinationRelease, err := destinationHelmClient.InstallOrUpgradeChart(context.Background(), &destinationChartSpec, &helm.GenericHelmOptions{})
+	if err != nil {
 		log.Fatalf("Error: Could not install or update Velero Helm chart on the destination Kubernetes cluster: %v", err)
 	}
+	recordObject(helmReleaseKind, destinationReleaseNamespace, destinationRelease.Name, ObjectActionInstalled)
 }
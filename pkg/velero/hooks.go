@@ -0,0 +1,194 @@
+package velero
+
+import (
+	"errors"
+	"fmt"
+	common "vresq/pkg/common"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	HookOnErrorContinue = "Continue"
+	HookOnErrorFail     = "Fail"
+)
+
+// RestoreHookStatus is the execution status of the hooks of a restore, as reported by Velero.
+type RestoreHookStatus struct {
+	HooksAttempted int64 `json:"hooksAttempted" yaml:"hooksAttempted"`
+	HooksFailed    int64 `json:"hooksFailed" yaml:"hooksFailed"`
+}
+
+// GetRestoreHookStatus extracts the execution status of the hooks of the given Velero restore.
+func GetRestoreHookStatus(restore unstructured.Unstructured) RestoreHookStatus {
+	return RestoreHookStatus{
+		HooksAttempted: nestedInt64(restore.Object, "status", "hookStatus", "hooksAttempted"),
+		HooksFailed:    nestedInt64(restore.Object, "status", "hookStatus", "hooksFailed"),
+	}
+}
+
+// ValidateRestoreHooks checks the restore hooks of the configuration before they are submitted to Velero.
+// It returns an error listing every invalid hook.
+func ValidateRestoreHooks(hooks []common.RestoreHook) error {
+	var errs []error
+	names := map[string]bool{}
+	for i, hook := range hooks {
+		if hook.Name == "" {
+			errs = append(errs, fmt.Errorf("restore hook %d: no name", i+1))
+		} else if names[hook.Name] {
+			errs = append(errs, fmt.Errorf("restore hook %s: name used by several hooks", hook.Name))
+		}
+		names[hook.Name] = true
+
+		if _, err := labels.ValidatedSelectorFromSet(hook.LabelSelector); err != nil {
+			errs = append(errs, fmt.Errorf("restore hook %s: invalid label selector: %v", hook.Name, err))
+		}
+		if len(hook.PostHooks) == 0 {
+			errs = append(errs, fmt.Errorf("restore hook %s: no post hook", hook.Name))
+		}
+		for j, postHook := range hook.PostHooks {
+			if err := validatePostHook(postHook); err != nil {
+				errs = append(errs, fmt.Errorf("restore hook %s, post hook %d: %v", hook.Name, j+1, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validatePostHook checks that a post hook is either a valid init hook or a valid exec hook.
+func validatePostHook(postHook common.RestorePostHook) error {
+	if (postHook.Init == nil) == (postHook.Exec == nil) {
+		return errors.New("exactly one of init or exec is required")
+	}
+
+	if postHook.Exec != nil {
+		if len(postHook.Exec.Command) == 0 {
+			return errors.New("exec hook without command")
+		}
+		if postHook.Exec.OnError != "" && postHook.Exec.OnError != HookOnErrorContinue && postHook.Exec.OnError != HookOnErrorFail {
+			return fmt.Errorf("invalid on-error %q, should be %s or %s", postHook.Exec.OnError, HookOnErrorContinue, HookOnErrorFail)
+		}
+		if postHook.Exec.ExecTimeout < 0 || postHook.Exec.WaitTimeout < 0 {
+			return errors.New("exec hook timeouts cannot be negative")
+		}
+		return nil
+	}
+
+	if len(postHook.Init.InitContainers) == 0 {
+		return errors.New("init hook without init containers")
+	}
+	if postHook.Init.Timeout < 0 {
+		return errors.New("init hook timeout cannot be negative")
+	}
+	for _, container := range postHook.Init.InitContainers {
+		if container.Name == "" || container.Image == "" {
+			return errors.New("init containers require a name and an image")
+		}
+		for _, volumeMount := range container.VolumeMounts {
+			if volumeMount.Name == "" || volumeMount.MountPath == "" {
+				return fmt.Errorf("init container %s: volume mounts require a name and a mount-path", container.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// buildRestoreHooks builds the hooks section of a Velero restore spec.
+func buildRestoreHooks(hooks []common.RestoreHook) map[string]interface{} {
+	resources := []interface{}{}
+	for _, hook := range hooks {
+		postHooks := []interface{}{}
+		for _, postHook := range hook.PostHooks {
+			if postHook.Exec != nil {
+				postHooks = append(postHooks, map[string]interface{}{"exec": buildExecHook(*postHook.Exec)})
+			} else if postHook.Init != nil {
+				postHooks = append(postHooks, map[string]interface{}{"init": buildInitHook(*postHook.Init)})
+			}
+		}
+		resource := map[string]interface{}{
+			"name":               hook.Name,
+			"includedNamespaces": hook.IncludedNamespaces,
+			"excludedNamespaces": hook.ExcludedNamespaces,
+			"includedResources":  hook.IncludedResources,
+			"excludedResources":  hook.ExcludedResources,
+			"postHooks":          postHooks,
+		}
+		if len(hook.LabelSelector) > 0 {
+			resource["labelSelector"] = map[string]interface{}{
+				"matchLabels": hook.LabelSelector,
+			}
+		}
+		resources = append(resources, resource)
+	}
+	return map[string]interface{}{
+		"resources": resources,
+	}
+}
+
+// buildExecHook builds an exec post hook of a Velero restore spec. Unset timeouts are left to the Velero defaults.
+func buildExecHook(hook common.RestoreExecHook) map[string]interface{} {
+	exec := map[string]interface{}{
+		"command": hook.Command,
+	}
+	if hook.Container != "" {
+		exec["container"] = hook.Container
+	}
+	if hook.OnError != "" {
+		exec["onError"] = hook.OnError
+	}
+	if hook.ExecTimeout > 0 {
+		exec["execTimeout"] = hook.ExecTimeout.String()
+	}
+	if hook.WaitTimeout > 0 {
+		exec["waitTimeout"] = hook.WaitTimeout.String()
+	}
+	if hook.WaitForReady {
+		exec["waitForReady"] = true
+	}
+	return exec
+}
+
+// buildInitHook builds an init post hook of a Velero restore spec.
+func buildInitHook(hook common.RestoreInitHook) map[string]interface{} {
+	containers := []interface{}{}
+	for _, container := range hook.InitContainers {
+		initContainer := map[string]interface{}{
+			"name":  container.Name,
+			"image": container.Image,
+		}
+		if len(container.Command) > 0 {
+			initContainer["command"] = container.Command
+		}
+		if len(container.Args) > 0 {
+			initContainer["args"] = container.Args
+		}
+		if len(container.Env) > 0 {
+			env := []interface{}{}
+			for _, envVar := range container.Env {
+				env = append(env, map[string]interface{}{"name": envVar.Name, "value": envVar.Value})
+			}
+			initContainer["env"] = env
+		}
+		if len(container.VolumeMounts) > 0 {
+			volumeMounts := []interface{}{}
+			for _, volumeMount := range container.VolumeMounts {
+				volumeMounts = append(volumeMounts, map[string]interface{}{
+					"name":      volumeMount.Name,
+					"mountPath": volumeMount.MountPath,
+					"readOnly":  volumeMount.ReadOnly,
+				})
+			}
+			initContainer["volumeMounts"] = volumeMounts
+		}
+		containers = append(containers, initContainer)
+	}
+
+	init := map[string]interface{}{
+		"initContainers": containers,
+	}
+	if hook.Timeout > 0 {
+		init["timeout"] = hook.Timeout.String()
+	}
+	return init
+}
@@ -0,0 +1,30 @@
+package velero
+
+import (
+	"fmt"
+	"strings"
+)
+
+// imageNameRuleKey is the key of an image name rule in the ConfigMap of the Velero change image name plugin, which reads every key.
+const imageNameRuleKey = "vresq-rule-%d"
+
+// ValidateImageNameRules checks that every image name rule is made of the part of the image names to replace and its replacement,
+// separated by a comma, as the Velero change image name plugin expects.
+func ValidateImageNameRules(rules []string) error {
+	for _, rule := range rules {
+		parts := strings.Split(rule, ",")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid image name rule %q, expected <old>,<new> like registry.example.com,registry.dr.example.com", rule)
+		}
+	}
+	return nil
+}
+
+// ImageNameRulesData returns the data of the ConfigMap of the Velero change image name plugin holding the given rules, in order.
+func ImageNameRulesData(rules []string) map[string]string {
+	data := map[string]string{}
+	for i, rule := range rules {
+		data[fmt.Sprintf(imageNameRuleKey, i+1)] = rule
+	}
+	return data
+}
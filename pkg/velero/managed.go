@@ -0,0 +1,258 @@
+package velero
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	ManagedByLabel      = "app.kubernetes.io/managed-by"
+	ManagedByValue      = "vresq"
+	RunIDLabel          = "vresq.avisto.com/run-id"
+	RunIDAnnotation     = "vresq.avisto.com/run-id"
+	CreatedAtAnnotation = "vresq.avisto.com/created-at"
+	// ChangesAnnotation records the data keys each run modified in an existing object, with their previous values.
+	ChangesAnnotation = "vresq.avisto.com/changes"
+	// helmDescriptionPrefix prefixes the description of the Helm releases installed by vresq, followed by the run ID.
+	helmDescriptionPrefix = "Installed by vresq, run-id="
+
+	ObjectActionCreated   = "created"
+	ObjectActionUpdated   = "updated"
+	ObjectActionReused    = "reused"
+	ObjectActionInstalled = "installed"
+)
+
+// DataChange records the data keys a run modified in an existing object, with their previous values.
+// A nil previous value stands for a key which did not exist before the run.
+type DataChange struct {
+	RunID    string             `json:"runID"`
+	Previous map[string]*string `json:"previous"`
+}
+
+// ObjectRecord describes an object written or reused in the destination cluster during the current run.
+type ObjectRecord struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	Action    string `json:"action" yaml:"action"`
+}
+
+var (
+	// runID identifies the current vresq run on every object it creates or modifies.
+	runID string
+	// objectRecords lists the objects written or reused in the destination cluster during the current run.
+	objectRecords = []ObjectRecord{}
+)
+
+// NewRunID generates a unique run ID, usable as a label value.
+func NewRunID() string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return time.Now().UTC().Format("20060102-150405")
+	}
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102-150405"), hex.EncodeToString(suffix))
+}
+
+// SetRunID sets the run ID used to mark the objects created or modified by vresq.
+func SetRunID(id string) {
+	runID = id
+}
+
+// GetRunID returns the run ID used to mark the objects created or modified by vresq.
+func GetRunID() string {
+	return runID
+}
+
+// markManaged labels and annotates an object created by vresq with the managed-by marker and the run ID.
+func markManaged(object *unstructured.Unstructured) {
+	labels := object.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ManagedByLabel] = ManagedByValue
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if runID != "" {
+		labels[RunIDLabel] = runID
+		annotations[RunIDAnnotation] = runID
+	}
+	annotations[CreatedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	object.SetLabels(labels)
+	object.SetAnnotations(annotations)
+}
+
+// markModified labels and annotates an existing object modified by vresq with the run ID.
+// The values of the given data keys before their first modification by the current run are recorded, so that each run can be reverted
+// on its own. Objects created by vresq keep the run ID of the run which created them, since they are deleted along with it.
+func markModified(object *unstructured.Unstructured, keys []string) error {
+	changes, err := getDataChanges(object)
+	if err != nil {
+		return err
+	}
+	data, _, _ := unstructured.NestedStringMap(object.Object, "data")
+	index := len(changes)
+	for i, change := range changes {
+		if change.RunID == runID {
+			index = i
+		}
+	}
+	if index == len(changes) {
+		changes = append(changes, DataChange{RunID: runID, Previous: map[string]*string{}})
+	}
+	for _, key := range keys {
+		if _, recorded := changes[index].Previous[key]; recorded {
+			continue
+		}
+		if value, found := data[key]; found {
+			changes[index].Previous[key] = &value
+		} else {
+			changes[index].Previous[key] = nil
+		}
+	}
+	if err := setDataChanges(object, changes); err != nil {
+		return err
+	}
+
+	labels := object.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	annotations := object.GetAnnotations()
+	if labels[ManagedByLabel] != ManagedByValue && runID != "" {
+		labels[RunIDLabel] = runID
+		annotations[RunIDAnnotation] = runID
+	}
+	object.SetLabels(labels)
+	object.SetAnnotations(annotations)
+	return nil
+}
+
+// revertDataChanges reverts the data keys modified by a run in an existing object. A key modified again by a later run keeps its value,
+// the later run then reverts it to the value from before the given run. The run ID markers of the object are removed with its last change.
+func revertDataChanges(object *unstructured.Unstructured, runID string) error {
+	changes, err := getDataChanges(object)
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i, change := range changes {
+		if change.RunID == runID {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+
+	data, _, _ := unstructured.NestedStringMap(object.Object, "data")
+	if data == nil {
+		data = map[string]string{}
+	}
+	for key, previous := range changes[index].Previous {
+		handedOver := false
+		for _, later := range changes[index+1:] {
+			if _, found := later.Previous[key]; found {
+				later.Previous[key] = previous
+				handedOver = true
+				break
+			}
+		}
+		if handedOver {
+			continue
+		}
+		if previous == nil {
+			delete(data, key)
+		} else {
+			data[key] = *previous
+		}
+	}
+	if err := unstructured.SetNestedStringMap(object.Object, data, "data"); err != nil {
+		return err
+	}
+	changes = append(changes[:index], changes[index+1:]...)
+	if err := setDataChanges(object, changes); err != nil {
+		return err
+	}
+
+	if object.GetLabels()[ManagedByLabel] == ManagedByValue {
+		return nil
+	}
+	labels := object.GetLabels()
+	annotations := object.GetAnnotations()
+	if len(changes) == 0 {
+		delete(labels, RunIDLabel)
+		delete(annotations, RunIDAnnotation)
+	} else {
+		labels[RunIDLabel] = changes[len(changes)-1].RunID
+		annotations[RunIDAnnotation] = changes[len(changes)-1].RunID
+	}
+	object.SetLabels(labels)
+	object.SetAnnotations(annotations)
+	return nil
+}
+
+// getDataChanges returns the data changes recorded in an object, in the order of the runs which made them.
+func getDataChanges(object *unstructured.Unstructured) ([]DataChange, error) {
+	changes := []DataChange{}
+	value, found := object.GetAnnotations()[ChangesAnnotation]
+	if !found {
+		return changes, nil
+	}
+	if err := json.Unmarshal([]byte(value), &changes); err != nil {
+		return nil, fmt.Errorf("could not read the changes recorded in %s %s: %v", object.GetKind(), object.GetName(), err)
+	}
+	return changes, nil
+}
+
+// setDataChanges records the data changes of an object, removing the annotation when there is none left.
+func setDataChanges(object *unstructured.Unstructured, changes []DataChange) error {
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if len(changes) == 0 {
+		delete(annotations, ChangesAnnotation)
+		object.SetAnnotations(annotations)
+		return nil
+	}
+	value, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("could not record the changes of %s %s: %v", object.GetKind(), object.GetName(), err)
+	}
+	annotations[ChangesAnnotation] = string(value)
+	object.SetAnnotations(annotations)
+	return nil
+}
+
+// hasDataChanges checks whether the given run, or any run when runID is empty, recorded data changes.
+func hasDataChanges(changes []DataChange, runID string) bool {
+	for _, change := range changes {
+		if runID == "" || change.RunID == runID {
+			return true
+		}
+	}
+	return false
+}
+
+// helmReleaseDescription returns the description of a Helm release installed by vresq during the current run.
+func helmReleaseDescription() string {
+	return helmDescriptionPrefix + runID
+}
+
+// recordObject records an object written or reused in the destination cluster during the current run.
+func recordObject(kind string, namespace string, name string, action string) {
+	objectRecords = append(objectRecords, ObjectRecord{Kind: kind, Namespace: namespace, Name: name, Action: action})
+}
+
+// GetObjectRecords returns the objects written or reused in the destination cluster during the current run.
+// In dry-run mode, they are the objects that would have been written.
+func GetObjectRecords() []ObjectRecord {
+	return objectRecords
+}
@@ -0,0 +1,177 @@
+package velero
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	NamespaceNew         = "New"
+	NamespaceEmpty       = "Empty"
+	NamespaceNonEmpty    = "NonEmpty"
+	NamespaceTerminating = "Terminating"
+
+	NamespaceConflictFail  = "fail"
+	NamespaceConflictSkip  = "skip"
+	NamespaceConflictMerge = "merge"
+
+	namespaceDeletionPollInterval = 5 * time.Second
+)
+
+var namespacesGVR = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}
+
+// defaultNamespaceObjects lists the objects Kubernetes creates in every namespace, which do not make a namespace non-empty.
+var defaultNamespaceObjects = map[string]string{
+	"configmaps":      "kube-root-ca.crt",
+	"serviceaccounts": "default",
+}
+
+// NamespaceState is the state of the destination namespace of a restored namespace in the destination cluster.
+type NamespaceState struct {
+	Source  string `json:"source" yaml:"source"`
+	Target  string `json:"target" yaml:"target"`
+	Status  string `json:"status" yaml:"status"`
+	Objects int    `json:"objects" yaml:"objects"`
+}
+
+// Conflicting checks whether restoring in the namespace would merge into live objects or fail because it is being deleted.
+func (s NamespaceState) Conflicting() bool {
+	return s.Status == NamespaceNonEmpty || s.Status == NamespaceTerminating
+}
+
+// ValidateNamespaceConflictPolicy checks the policy applied to the conflicting destination namespaces, empty meaning prompting for it.
+func ValidateNamespaceConflictPolicy(policy string) error {
+	switch policy {
+	case "", NamespaceConflictFail, NamespaceConflictSkip, NamespaceConflictMerge:
+		return nil
+	}
+	return fmt.Errorf("invalid namespace conflict policy %q, should be %s, %s or %s", policy, NamespaceConflictFail, NamespaceConflictSkip, NamespaceConflictMerge)
+}
+
+// GetNamespaceStates classifies the destination namespace of every given namespace as new, empty, non-empty or terminating,
+// the namespaces without mapping being restored in a namespace of the same name.
+func GetNamespaceStates(dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, namespaces []string, mapping map[string]string) ([]NamespaceState, error) {
+	resources, err := namespacedResources(discoveryClient)
+	if err != nil {
+		return nil, err
+	}
+
+	states := []NamespaceState{}
+	for _, namespace := range namespaces {
+		if namespace == "*" || namespace == "" {
+			continue
+		}
+		target := namespace
+		if mappedTarget, mapped := mapping[namespace]; mapped {
+			target = mappedTarget
+		}
+		state, err := getNamespaceState(dynamicClient, resources, target)
+		if err != nil {
+			return nil, err
+		}
+		state.Source = namespace
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Source < states[j].Source
+	})
+	return states, nil
+}
+
+// GetNamespaceState classifies a single namespace of the destination cluster as new, empty, non-empty or terminating.
+func GetNamespaceState(dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, namespace string) (NamespaceState, error) {
+	resources, err := namespacedResources(discoveryClient)
+	if err != nil {
+		return NamespaceState{}, err
+	}
+	return getNamespaceState(dynamicClient, resources, namespace)
+}
+
+// WaitForNamespaceDeletion waits until a terminating namespace is deleted, or fails after the timeout.
+func WaitForNamespaceDeletion(dynamicClient dynamic.Interface, namespace string, timeout time.Duration) error {
+	log.Printf("Waiting for namespace %s to be deleted, for up to %s", namespace, timeout)
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := dynamicClient.Resource(namespacesGVR).Get(context.TODO(), namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			log.Printf("Namespace %s deleted", namespace)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not get namespace %s: %v", namespace, err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("namespace %s is still terminating after %s", namespace, timeout)
+		}
+		time.Sleep(namespaceDeletionPollInterval)
+	}
+}
+
+// getNamespaceState classifies a namespace by its phase and by the objects of the given resources it holds.
+func getNamespaceState(dynamicClient dynamic.Interface, resources []schema.GroupVersionResource, namespace string) (NamespaceState, error) {
+	state := NamespaceState{Source: namespace, Target: namespace, Status: NamespaceNew}
+	object, err := dynamicClient.Resource(namespacesGVR).Get(context.TODO(), namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("could not get namespace %s: %v", namespace, err)
+	}
+
+	for _, gvr := range resources {
+		list, err := dynamicClient.Resource(gvr).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return state, fmt.Errorf("could not list %s in namespace %s: %v", gvr.String(), namespace, err)
+		}
+		for _, item := range list.Items {
+			if defaultNamespaceObjects[gvr.Resource] != item.GetName() {
+				state.Objects++
+			}
+		}
+	}
+
+	switch {
+	case nestedString(object.Object, "status", "phase") == NamespaceTerminating || object.GetDeletionTimestamp() != nil:
+		state.Status = NamespaceTerminating
+	case state.Objects > 0:
+		state.Status = NamespaceNonEmpty
+	default:
+		state.Status = NamespaceEmpty
+	}
+	return state, nil
+}
+
+// namespacedResources discovers the namespaced resources of the cluster whose objects can be listed, events excluded.
+func namespacedResources(discoveryClient discovery.DiscoveryInterface) ([]schema.GroupVersionResource, error) {
+	resourceLists, err := discoveryClient.ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("could not discover the API resources of the cluster: %v", err)
+	}
+	// Some aggregated APIs may be unavailable, the objects of the other ones are still counted
+
+	resources := []schema.GroupVersionResource{}
+	for _, resourceList := range resourceLists {
+		groupVersion, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			// Events are recorded in namespaces without any other object, and are served by two groups
+			if strings.Contains(resource.Name, "/") || resource.Name == "events" || !hasVerbs(resource, "list") {
+				continue
+			}
+			resources = append(resources, groupVersion.WithResource(resource.Name))
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,166 @@
+package velero
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	NamespaceRulePrefix   = "prefix"
+	NamespaceRuleSuffix   = "suffix"
+	NamespaceRuleRegex    = "regex"
+	NamespaceRuleTemplate = "template"
+	// namespaceRegexSeparator separates the pattern from the replacement of a regex rule.
+	namespaceRegexSeparator = "=>"
+)
+
+// NamespaceMappingRule computes the destination namespace of a source namespace.
+type NamespaceMappingRule struct {
+	Kind        string
+	Value       string
+	regex       *regexp.Regexp
+	replacement string
+	template    *template.Template
+}
+
+// NamespaceTemplateData holds the values available to the template namespace mapping rules.
+type NamespaceTemplateData struct {
+	// Namespace is the namespace computed by the previous rules, SourceNamespace the namespace in the backup.
+	Namespace       string
+	SourceNamespace string
+	RestoreName     string
+	BackupName      string
+}
+
+// ParseNamespaceMappingRules parses namespace mapping rules like "prefix=dr-", "suffix=-restore",
+// "regex=^team-(.*)$=>restored-$1" or "template={{.Namespace}}-{{.BackupName}}". It returns an error listing every invalid rule.
+func ParseNamespaceMappingRules(rules []string) ([]NamespaceMappingRule, error) {
+	parsedRules := []NamespaceMappingRule{}
+	var errs []error
+	for _, rule := range rules {
+		kind, value, found := strings.Cut(rule, "=")
+		if !found {
+			errs = append(errs, fmt.Errorf("invalid namespace mapping rule %q, should be <prefix|suffix|regex|template>=<value>", rule))
+			continue
+		}
+		parsedRule := NamespaceMappingRule{Kind: kind, Value: value}
+		switch kind {
+		case NamespaceRulePrefix, NamespaceRuleSuffix:
+		case NamespaceRuleRegex:
+			pattern, replacement, found := strings.Cut(value, namespaceRegexSeparator)
+			if !found {
+				errs = append(errs, fmt.Errorf("invalid namespace mapping rule %q, should be regex=<pattern>%s<replacement>", rule, namespaceRegexSeparator))
+				continue
+			}
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid namespace mapping rule %q: %v", rule, err))
+				continue
+			}
+			parsedRule.regex = regex
+			parsedRule.replacement = replacement
+		case NamespaceRuleTemplate:
+			tmpl, err := template.New(rule).Option("missingkey=error").Parse(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid namespace mapping rule %q: %v", rule, err))
+				continue
+			}
+			parsedRule.template = tmpl
+		default:
+			errs = append(errs, fmt.Errorf("unknown namespace mapping rule %q, should be prefix, suffix, regex or template", kind))
+			continue
+		}
+		parsedRules = append(parsedRules, parsedRule)
+	}
+	return parsedRules, errors.Join(errs...)
+}
+
+// ExpandNamespaceMapping computes the destination namespace of every source namespace without an explicit mapping,
+// by applying the rules in order, each one to the result of the previous one. Regex rules only apply to the namespaces they match.
+// It returns a new mapping, holding the explicit mapping and the namespaces renamed by the rules.
+func ExpandNamespaceMapping(rules []NamespaceMappingRule, namespaces []string, mapping map[string]string, restoreName string, backupName string) (map[string]string, error) {
+	expanded := map[string]string{}
+	for source, target := range mapping {
+		expanded[source] = target
+	}
+	for _, namespace := range namespaces {
+		if _, mapped := expanded[namespace]; mapped {
+			continue
+		}
+		target := namespace
+		for _, rule := range rules {
+			var err error
+			target, err = rule.apply(NamespaceTemplateData{
+				Namespace:       target,
+				SourceNamespace: namespace,
+				RestoreName:     restoreName,
+				BackupName:      backupName,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("could not map namespace %s: %v", namespace, err)
+			}
+		}
+		if target != namespace {
+			expanded[namespace] = target
+		}
+	}
+	return expanded, nil
+}
+
+// apply returns the namespace computed by the rule.
+func (r NamespaceMappingRule) apply(data NamespaceTemplateData) (string, error) {
+	switch r.Kind {
+	case NamespaceRulePrefix:
+		return r.Value + data.Namespace, nil
+	case NamespaceRuleSuffix:
+		return data.Namespace + r.Value, nil
+	case NamespaceRuleRegex:
+		if !r.regex.MatchString(data.Namespace) {
+			return data.Namespace, nil
+		}
+		return r.regex.ReplaceAllString(data.Namespace, r.replacement), nil
+	case NamespaceRuleTemplate:
+		var builder strings.Builder
+		if err := r.template.Execute(&builder, data); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(builder.String()), nil
+	}
+	return data.Namespace, nil
+}
+
+// ValidateNamespaceMapping checks that every destination namespace is a valid namespace name, of at most 63 characters,
+// and that no two restored namespaces end up in the same destination namespace, the namespaces without mapping keeping their name.
+// It returns an error listing every issue.
+func ValidateNamespaceMapping(mapping map[string]string, namespaces []string) error {
+	sources := make([]string, 0, len(mapping))
+	for source := range mapping {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var errs []error
+	targets := map[string]string{}
+	for _, namespace := range namespaces {
+		if _, mapped := mapping[namespace]; !mapped && namespace != "*" {
+			targets[namespace] = namespace
+		}
+	}
+	for _, source := range sources {
+		target := mapping[source]
+		for _, message := range validation.IsDNS1123Label(target) {
+			errs = append(errs, fmt.Errorf("invalid destination namespace %q for namespace %s: %s", target, source, message))
+		}
+		if otherSource, found := targets[target]; found {
+			errs = append(errs, fmt.Errorf("namespaces %s and %s are both restored in namespace %s", otherSource, source, target))
+		}
+		targets[target] = source
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,76 @@
+package velero
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	zoneLabel      = "topology.kubernetes.io/zone"
+	regionLabel    = "topology.kubernetes.io/region"
+	nodeRolePrefix = "node-role.kubernetes.io/"
+)
+
+var (
+	nodeGVR = schema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "nodes",
+	}
+)
+
+// Node describes a node of a cluster.
+type Node struct {
+	Name   string `json:"name" yaml:"name"`
+	Zone   string `json:"zone,omitempty" yaml:"zone,omitempty"`
+	Region string `json:"region,omitempty" yaml:"region,omitempty"`
+	// Provider is the cloud provider of the node, the scheme of its provider ID like aws or gce.
+	Provider string   `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Roles    []string `json:"roles,omitempty" yaml:"roles,omitempty"`
+}
+
+// ListNodes lists the nodes of a cluster, sorted by name.
+func ListNodes(dynamicClient dynamic.Interface) ([]Node, error) {
+	list, err := dynamicClient.Resource(nodeGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{}
+	for _, item := range list.Items {
+		node := Node{Name: item.GetName(), Zone: item.GetLabels()[zoneLabel], Region: item.GetLabels()[regionLabel], Roles: []string{}}
+		if provider, _, found := strings.Cut(nestedString(item.Object, "spec", "providerID"), "://"); found {
+			node.Provider = provider
+		}
+		for label := range item.GetLabels() {
+			if role := strings.TrimPrefix(label, nodeRolePrefix); role != label && role != "" {
+				node.Roles = append(node.Roles, role)
+			}
+		}
+		sort.Strings(node.Roles)
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes, nil
+}
+
+// ValidateNodeMapping checks that every node of a node mapping exists in the destination cluster.
+func ValidateNodeMapping(mapping map[string]string, destinationNodes []Node) error {
+	destinationNames := map[string]bool{}
+	for _, node := range destinationNodes {
+		destinationNames[node.Name] = true
+	}
+	for _, source := range sortedStringMapKeys(mapping) {
+		if !destinationNames[mapping[source]] {
+			return fmt.Errorf("node %s is mapped to %s, which does not exist in the destination cluster", source, mapping[source])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package velero
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	common "vresq/pkg/common"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	resourceModifiersVersion = "v1"
+	// resourceModifiersDataKey is the key of the rules in the resource modifiers ConfigMap, Velero requires exactly one key.
+	resourceModifiersDataKey = "resource-modifiers.yaml"
+)
+
+// ResourceModifiers holds the resource modifier rules, in the format Velero reads from the resource modifiers ConfigMap.
+type ResourceModifiers struct {
+	Version               string                 `yaml:"version"`
+	ResourceModifierRules []ResourceModifierRule `yaml:"resourceModifierRules"`
+}
+
+// ResourceModifierRule holds the JSON patches applied to the restored objects matching its conditions.
+type ResourceModifierRule struct {
+	Conditions ResourceModifierConditions `yaml:"conditions"`
+	Patches    []JSONPatch                `yaml:"patches"`
+}
+
+// ResourceModifierConditions selects the restored objects a resource modifier rule applies to.
+type ResourceModifierConditions struct {
+	GroupResource     string                 `yaml:"groupResource"`
+	ResourceNameRegex string                 `yaml:"resourceNameRegex,omitempty"`
+	Namespaces        []string               `yaml:"namespaces,omitempty"`
+	LabelSelector     *ResourceLabelSelector `yaml:"labelSelector,omitempty"`
+}
+
+// ResourceLabelSelector selects objects by their labels.
+type ResourceLabelSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels,omitempty"`
+}
+
+// JSONPatch is a JSON patch operation applied by Velero.
+type JSONPatch struct {
+	Operation string `yaml:"operation"`
+	From      string `yaml:"from,omitempty"`
+	Path      string `yaml:"path"`
+	Value     string `yaml:"value,omitempty"`
+}
+
+// jsonPointerRegex matches a JSON pointer, whose "~" are escaped as "~0" or "~1".
+var jsonPointerRegex = regexp.MustCompile(`^(/([^~/]|~[01])*)+$`)
+
+// ResourceModifiersConfigMapName returns the name of the resource modifiers ConfigMap of a restore.
+func ResourceModifiersConfigMapName(restoreName string) string {
+	return fmt.Sprintf("%s-resource-modifiers", restoreName)
+}
+
+// HasResourceModifiers checks whether the restore options hold resource modifiers, from the config file or a resource modifier file.
+func HasResourceModifiers(options common.VeleroRestoreOptions) bool {
+	return len(options.ResourceModifiers) > 0 || options.ResourceModifierFile != ""
+}
+
+// LoadResourceModifiers returns the resource modifier rules of the config file followed by the rules of the resource modifier file, if any,
+// and validates them. It returns an error listing every invalid rule.
+func LoadResourceModifiers(options common.VeleroRestoreOptions) (ResourceModifiers, error) {
+	resourceModifiers := ResourceModifiers{Version: resourceModifiersVersion}
+	var errs []error
+	for i, modifier := range options.ResourceModifiers {
+		rule, err := newResourceModifierRule(modifier)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resource modifier %d: %v", i+1, err))
+			continue
+		}
+		resourceModifiers.ResourceModifierRules = append(resourceModifiers.ResourceModifierRules, rule)
+	}
+	if len(errs) > 0 {
+		return ResourceModifiers{}, errors.Join(errs...)
+	}
+
+	if options.ResourceModifierFile != "" {
+		content, err := os.ReadFile(options.ResourceModifierFile)
+		if err != nil {
+			return ResourceModifiers{}, fmt.Errorf("could not read resource modifier file: %v", err)
+		}
+		fileModifiers := ResourceModifiers{}
+		if err := yaml.UnmarshalStrict(content, &fileModifiers); err != nil {
+			return ResourceModifiers{}, fmt.Errorf("could not parse resource modifier file %s: %v", options.ResourceModifierFile, err)
+		}
+		if fileModifiers.Version != resourceModifiersVersion {
+			return ResourceModifiers{}, fmt.Errorf("unsupported version %q in resource modifier file %s, should be %s", fileModifiers.Version, options.ResourceModifierFile, resourceModifiersVersion)
+		}
+		resourceModifiers.ResourceModifierRules = append(resourceModifiers.ResourceModifierRules, fileModifiers.ResourceModifierRules...)
+	}
+
+	for i, rule := range resourceModifiers.ResourceModifierRules {
+		if err := validateResourceModifierRule(rule); err != nil {
+			errs = append(errs, fmt.Errorf("resource modifier %d: %v", i+1, err))
+		}
+	}
+	return resourceModifiers, errors.Join(errs...)
+}
+
+// BuildResourceModifiersConfigMap builds the ConfigMap holding the resource modifier rules of a restore in the Velero namespace.
+func BuildResourceModifiersConfigMap(namespace string, restoreName string, resourceModifiers ResourceModifiers) (unstructured.Unstructured, error) {
+	rules, err := yaml.Marshal(resourceModifiers)
+	if err != nil {
+		return unstructured.Unstructured{}, fmt.Errorf("could not render resource modifiers: %v", err)
+	}
+
+	return unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      ResourceModifiersConfigMapName(restoreName),
+				"namespace": namespace,
+			},
+			"data": map[string]interface{}{
+				resourceModifiersDataKey: string(rules),
+			},
+		},
+	}, nil
+}
+
+// CreateResourceModifiersConfigMap creates the ConfigMap holding the resource modifier rules of a restore in the Velero namespace.
+// In dry-run mode, the ConfigMap is only rendered.
+func CreateResourceModifiersConfigMap(dynamicClient dynamic.Interface, namespace string, restoreName string, resourceModifiers ResourceModifiers) error {
+	configMap, err := BuildResourceModifiersConfigMap(namespace, restoreName, resourceModifiers)
+	if err != nil {
+		return err
+	}
+	name := configMap.GetName()
+	if err := createResource(dynamicClient, namespace, &configMap, "configmaps"); err != nil {
+		return fmt.Errorf("could not create resource modifiers ConfigMap %s: %v", name, err)
+	}
+	if !IsDryRun() {
+		log.Printf("ConfigMap %s with %d resource modifier rules created in namespace %s", name, len(resourceModifiers.ResourceModifierRules), namespace)
+	}
+	return nil
+}
+
+// newResourceModifierRule converts a resource modifier of the config file to a Velero resource modifier rule.
+func newResourceModifierRule(modifier common.ResourceModifier) (ResourceModifierRule, error) {
+	rule := ResourceModifierRule{
+		Conditions: ResourceModifierConditions{
+			GroupResource:     modifier.GroupResource,
+			ResourceNameRegex: modifier.ResourceNameRegex,
+			Namespaces:        modifier.Namespaces,
+		},
+	}
+	if len(modifier.LabelSelector) > 0 {
+		rule.Conditions.LabelSelector = &ResourceLabelSelector{MatchLabels: modifier.LabelSelector}
+	}
+	for i, patch := range modifier.Patches {
+		value, err := patchValue(patch.Value)
+		if err != nil {
+			return ResourceModifierRule{}, fmt.Errorf("patch %d: %v", i+1, err)
+		}
+		rule.Patches = append(rule.Patches, JSONPatch{
+			Operation: patch.Operation,
+			From:      patch.From,
+			Path:      patch.Path,
+			Value:     value,
+		})
+	}
+	return rule, nil
+}
+
+// patchValue formats the value of a JSON patch of the config file as Velero expects it, numbers and booleans being decoded as such.
+// YAML maps and lists are rejected, since their keys are lowercased when the config file is read.
+func patchValue(value interface{}) (string, error) {
+	switch typed := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return typed, nil
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		return "", errors.New(`the value is a YAML map or list, give JSON objects and arrays as quoted strings, like '{"app": "web"}'`)
+	default:
+		return fmt.Sprintf("%v", typed), nil
+	}
+}
+
+// validateResourceModifierRule checks the conditions and the JSON patches of a resource modifier rule, as Velero would during the restore.
+func validateResourceModifierRule(rule ResourceModifierRule) error {
+	if rule.Conditions.GroupResource == "" {
+		return errors.New("no group resource")
+	}
+	if strings.ContainsAny(rule.Conditions.GroupResource, " /") {
+		return fmt.Errorf("invalid group resource %q, should be <resource> or <resource>.<group>", rule.Conditions.GroupResource)
+	}
+	if _, err := regexp.Compile(rule.Conditions.ResourceNameRegex); err != nil {
+		return fmt.Errorf("invalid resource name regex: %v", err)
+	}
+	if rule.Conditions.LabelSelector != nil {
+		if _, err := labels.ValidatedSelectorFromSet(rule.Conditions.LabelSelector.MatchLabels); err != nil {
+			return fmt.Errorf("invalid label selector: %v", err)
+		}
+	}
+	if len(rule.Patches) == 0 {
+		return errors.New("no patches")
+	}
+	for i, patch := range rule.Patches {
+		if err := validateJSONPatch(patch); err != nil {
+			return fmt.Errorf("patch %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validateJSONPatch checks the operation and the paths of a JSON patch, and that it renders to valid JSON.
+func validateJSONPatch(patch JSONPatch) error {
+	switch patch.Operation {
+	case "add", "replace", "test", "remove":
+	case "move", "copy":
+		if !jsonPointerRegex.MatchString(patch.From) {
+			return fmt.Errorf("invalid from %q, %s requires a JSON pointer like /spec/replicas", patch.From, patch.Operation)
+		}
+	default:
+		return fmt.Errorf("invalid operation %q, should be add, remove, replace, move, copy or test", patch.Operation)
+	}
+	if !jsonPointerRegex.MatchString(patch.Path) {
+		return fmt.Errorf("invalid path %q, should be a JSON pointer like /spec/replicas", patch.Path)
+	}
+
+	var operations []map[string]interface{}
+	if err := json.Unmarshal([]byte("["+jsonPatchString(patch)+"]"), &operations); err != nil {
+		return fmt.Errorf("invalid value %q: %v", patch.Value, err)
+	}
+	return nil
+}
+
+// jsonPatchString renders a JSON patch as Velero does, without escaping: values are quoted unless they are null, a boolean, a number,
+// a JSON object or array.
+func jsonPatchString(patch JSONPatch) string {
+	value := patch.Value
+	_, boolErr := strconv.ParseBool(value)
+	_, floatErr := strconv.ParseFloat(value, 64)
+	if value == "" || value != "null" && boolErr != nil && floatErr != nil && !strings.HasPrefix(value, "{") && !strings.HasPrefix(value, "[") {
+		value = `"` + value + `"`
+	}
+	return fmt.Sprintf(`{"op": "%s", "from": "%s", "path": "%s", "value": %s}`, patch.Operation, patch.From, patch.Path, value)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	common "vresq/pkg/common"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -14,9 +15,124 @@ import (
 	"k8s.io/client-go/dynamic"
 )
 
-// CreateVeleroRestore creates a Velero restore with the specified options.
-// It returns an error if the creation or watching of the restore fails.
-func CreateVeleroRestore(dynamicClient dynamic.Interface, namespace string, name string, options common.VeleroRestoreOptions) error {
+// Outcomes of a restore watch.
+const (
+	watchExpired = iota
+	watchFinished
+	watchDeleted
+)
+
+var (
+	restoreGVR = schema.GroupVersionResource{
+		Group:    veleroApiGroup,
+		Version:  apiVersion,
+		Resource: "restores",
+	}
+)
+
+// RestoreSummary contains the main fields of a Velero restore.
+type RestoreSummary struct {
+	Name                string `json:"name" yaml:"name"`
+	BackupName          string `json:"backupName" yaml:"backupName"`
+	ScheduleName        string `json:"scheduleName,omitempty" yaml:"scheduleName,omitempty"`
+	Phase               string `json:"phase" yaml:"phase"`
+	StartTimestamp      string `json:"startTimestamp" yaml:"startTimestamp"`
+	CompletionTimestamp string `json:"completionTimestamp" yaml:"completionTimestamp"`
+	Errors              int64  `json:"errors" yaml:"errors"`
+	Warnings            int64  `json:"warnings" yaml:"warnings"`
+}
+
+// RestoreDetails extends RestoreSummary with the progress and validation errors of a restore.
+type RestoreDetails struct {
+	RestoreSummary   `json:",inline" yaml:",inline"`
+	TotalItems       int64             `json:"totalItems" yaml:"totalItems"`
+	ItemsRestored    int64             `json:"itemsRestored" yaml:"itemsRestored"`
+	ValidationErrors []string          `json:"validationErrors" yaml:"validationErrors"`
+	NamespaceMapping map[string]string `json:"namespaceMapping" yaml:"namespaceMapping"`
+	HookStatus       RestoreHookStatus `json:"hookStatus" yaml:"hookStatus"`
+}
+
+// RestoreFailedError indicates that a restore finished in a failed phase (Failed, PartiallyFailed or FailedValidation).
+type RestoreFailedError struct {
+	Name  string
+	Phase string
+}
+
+// Error returns the error message.
+func (r RestoreFailedError) Error() string {
+	return fmt.Sprintf("restore %s finished with phase %s", r.Name, r.Phase)
+}
+
+// GetRestoreSummary extracts the main fields of the given Velero restore.
+func GetRestoreSummary(restore unstructured.Unstructured) RestoreSummary {
+	return RestoreSummary{
+		Name:                restore.GetName(),
+		BackupName:          nestedString(restore.Object, "spec", "backupName"),
+		ScheduleName:        nestedString(restore.Object, "spec", "scheduleName"),
+		Phase:               nestedString(restore.Object, "status", "phase"),
+		StartTimestamp:      nestedString(restore.Object, "status", "startTimestamp"),
+		CompletionTimestamp: nestedString(restore.Object, "status", "completionTimestamp"),
+		Errors:              nestedInt64(restore.Object, "status", "errors"),
+		Warnings:            nestedInt64(restore.Object, "status", "warnings"),
+	}
+}
+
+// GetRestoreDetails extracts the main fields, the progress and the validation errors of the given Velero restore.
+func GetRestoreDetails(restore unstructured.Unstructured) RestoreDetails {
+	namespaceMapping, _, _ := unstructured.NestedStringMap(restore.Object, "spec", "namespaceMapping")
+	return RestoreDetails{
+		RestoreSummary:   GetRestoreSummary(restore),
+		TotalItems:       nestedInt64(restore.Object, "status", "progress", "totalItems"),
+		ItemsRestored:    nestedInt64(restore.Object, "status", "progress", "itemsRestored"),
+		ValidationErrors: nestedStringSlice(restore.Object, "status", "validationErrors"),
+		NamespaceMapping: namespaceMapping,
+		HookStatus:       GetRestoreHookStatus(restore),
+	}
+}
+
+// GetRestore retrieves a Velero restore by name from the specified namespace.
+func GetRestore(dynamicClient dynamic.Interface, namespace string, name string) (unstructured.Unstructured, error) {
+	restore, err := dynamicClient.Resource(restoreGVR).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return unstructured.Unstructured{}, err
+	}
+
+	return *restore, nil
+}
+
+// ListRestores lists all Velero restores in the specified namespace.
+func ListRestores(dynamicClient dynamic.Interface, namespace string) (*unstructured.UnstructuredList, error) {
+	restores, err := dynamicClient.Resource(restoreGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return restores, nil
+}
+
+// DeleteRestore deletes a Velero restore by name from the specified namespace.
+// Objects that were restored by it are left untouched.
+func DeleteRestore(dynamicClient dynamic.Interface, namespace string, name string) error {
+	return dynamicClient.Resource(restoreGVR).Namespace(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+}
+
+// WatchRestore watches an existing Velero restore until its status is completed or fails.
+// It returns an error if the restore fails.
+func WatchRestore(dynamicClient dynamic.Interface, namespace string, name string) error {
+	if _, err := GetRestore(dynamicClient, namespace, name); err != nil {
+		return err
+	}
+	return watchRestore(dynamicClient, namespace, name, restoreGVR)
+}
+
+// BuildVeleroRestore builds the Velero Restore object with the specified options, as CreateVeleroRestore creates it.
+func BuildVeleroRestore(namespace string, name string, options common.VeleroRestoreOptions) unstructured.Unstructured {
+	// Velero rejects restores with both a backup and a schedule, the backup resolved from a schedule takes precedence
+	scheduleName := options.ScheduleName
+	if options.BackupName != "" {
+		scheduleName = ""
+	}
+
 	// Define the restore object
 	restore := unstructured.Unstructured{
 		Object: map[string]interface{}{
@@ -28,42 +144,54 @@ func CreateVeleroRestore(dynamicClient dynamic.Interface, namespace string, name
 			},
 			"spec": map[string]interface{}{
 				"backupName":              options.BackupName,
-				"scheduleName":            options.ScheduleName,
+				"scheduleName":            scheduleName,
 				"itemOperationTimeout":    options.ItemOperationTimeout.String(),
 				"includedNamespaces":      options.IncludedNamespaces,
 				"excludedNamespaces":      options.ExcludedNamespaces,
 				"includedResources":       options.IncludedResources,
 				"excludedResources":       options.ExcludedResources,
 				"includeClusterResources": options.IncludeClusterResources,
-				"labelSelector": map[string]interface{}{
-					"matchLabels": options.LabelSelector,
-				},
-				"orLabelSelectors":       parseOrLabels(options.OrLabelSelectors),
-				"namespaceMapping":       options.NamespaceMapping,
-				"restorePVs":             options.RestorePVs,
-				"preserveNodePorts":      options.PreserveNodePorts,
-				"existingResourcePolicy": options.ExistingResourcePolicy,
+				"orLabelSelectors":        parseOrLabelSelectors(options),
+				"namespaceMapping":        options.NamespaceMapping,
+				"restorePVs":              options.RestorePVs,
+				"preserveNodePorts":       options.PreserveNodePorts,
+				"existingResourcePolicy":  options.ExistingResourcePolicy,
 			},
 		},
 	}
-
-	// Define the Velero restore GVR
-	groupVersionResource := schema.GroupVersionResource{
-		Group:    veleroApiGroup,
-		Version:  apiVersion,
-		Resource: "restores",
+	// Invalid selectors are reported by ValidateLabelSelectors before the restore is built
+	if labelSelector, err := parseLabelSelector(options.LabelSelector); err == nil && labelSelector != nil {
+		restore.Object["spec"].(map[string]interface{})["labelSelector"] = labelSelector
+	}
+	if len(options.RestoreHooks) > 0 {
+		restore.Object["spec"].(map[string]interface{})["hooks"] = buildRestoreHooks(options.RestoreHooks)
+	}
+	if HasResourceModifiers(options) {
+		restore.Object["spec"].(map[string]interface{})["resourceModifier"] = map[string]interface{}{
+			"kind": "ConfigMap",
+			"name": ResourceModifiersConfigMapName(name),
+		}
 	}
+	return restore
+}
+
+// CreateVeleroRestore creates a Velero restore with the specified options.
+// It returns an error if the creation or watching of the restore fails. In dry-run mode, the restore is only rendered.
+func CreateVeleroRestore(dynamicClient dynamic.Interface, namespace string, name string, options common.VeleroRestoreOptions) error {
+	restore := BuildVeleroRestore(namespace, name, options)
 
 	// Create the restore resource
 	err := createResource(dynamicClient, namespace, &restore, "restores")
 	if err != nil {
 		return err
+	} else if IsDryRun() {
+		return nil
 	} else {
 		log.Println("Velero Restore created successfully")
 	}
 
 	// Watch the restore until it's completed or fails
-	err = watchRestore(dynamicClient, namespace, name, groupVersionResource)
+	err = watchRestore(dynamicClient, namespace, name, restoreGVR)
 	if err != nil {
 		return err
 	}
@@ -72,35 +200,56 @@ func CreateVeleroRestore(dynamicClient dynamic.Interface, namespace string, name
 }
 
 // watchRestore watches the Velero restore until its status is completed or fails.
+// The watch is re-established when the API server closes it, which happens regularly during long restores.
 // It returns an error if the restore fails.
 func watchRestore(dynamicClient dynamic.Interface, namespace, restoreName string, veleroRestoreGVR schema.GroupVersionResource) error {
 	log.Printf("Watching restore '%s' in namespace '%s'\n", restoreName, namespace)
 
-	// Set up the watch interface
-	watcher, err := dynamicClient.Resource(veleroRestoreGVR).Namespace(namespace).Watch(context.TODO(), metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("metadata.name=%s", restoreName),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to watch restore: %v", err)
-	}
-	defer watcher.Stop()
+	for {
+		// A restore deleted while the watch was closed would never send another event
+		_, err := dynamicClient.Resource(veleroRestoreGVR).Namespace(namespace).Get(context.TODO(), restoreName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("restore %s was deleted while being watched", restoreName)
+		}
+
+		// Set up the watch interface
+		watcher, err := dynamicClient.Resource(veleroRestoreGVR).Namespace(namespace).Watch(context.TODO(), metav1.ListOptions{
+			FieldSelector: fmt.Sprintf("metadata.name=%s", restoreName),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to watch restore: %v", err)
+		}
 
-	// Define a channel to signal the end of watching
-	stopCh := make(chan struct{})
+		// Define a channel to signal the end of watching
+		stopCh := make(chan int)
 
-	// Watch for changes in a separate goroutine
-	go watchChanges(dynamicClient, namespace, restoreName, veleroRestoreGVR, stopCh, watcher)
+		// Watch for changes in a separate goroutine
+		go watchChanges(dynamicClient, namespace, restoreName, veleroRestoreGVR, stopCh, watcher)
 
-	// Wait for the watch to be stopped (when status is completed or failed)
-	<-stopCh
+		// Wait for the watch to be stopped (when status is completed or failed, when the restore is deleted, or when the watch expired)
+		outcome := <-stopCh
+		watcher.Stop()
+		if outcome == watchDeleted {
+			return fmt.Errorf("restore %s was deleted while being watched", restoreName)
+		}
+		if outcome == watchFinished {
+			break
+		}
+		log.Println("Restore watch expired, watching again")
+	}
 
 	// Check the final status of the restore
 	return checkFinalStatus(dynamicClient, namespace, restoreName, veleroRestoreGVR)
 }
 
 // watchChanges watches for changes in the restore status until it's completed or fails.
-func watchChanges(dynamicClient dynamic.Interface, namespace, restoreName string, veleroRestoreGVR schema.GroupVersionResource, stopCh chan struct{}, watcher watch.Interface) {
+// It sends watchFinished on stopCh when the restore is finished, watchDeleted when it is deleted, and watchExpired when the watch was closed before.
+func watchChanges(dynamicClient dynamic.Interface, namespace, restoreName string, veleroRestoreGVR schema.GroupVersionResource, stopCh chan int, watcher watch.Interface) {
 	for event := range watcher.ResultChan() {
+		if event.Type == watch.Deleted {
+			stopCh <- watchDeleted
+			return
+		}
 		restore, isUnstructured := event.Object.(*unstructured.Unstructured)
 		if !isUnstructured {
 			log.Println("Unexpected object type received from watch event")
@@ -118,10 +267,11 @@ func watchChanges(dynamicClient dynamic.Interface, namespace, restoreName string
 
 		// Check if the status is completed or failed
 		if statusPhase == "Completed" || strings.Contains(strings.ToLower(statusPhase), "failed") {
-			close(stopCh)
+			stopCh <- watchFinished
 			return
 		}
 	}
+	stopCh <- watchExpired
 }
 
 // checkFinalStatus checks the final status of the restore after watching.
@@ -146,9 +296,9 @@ func checkFinalStatus(dynamicClient dynamic.Interface, namespace, restoreName st
 	// Check if the status is completed or failed
 	if finalStatusPhase == "Completed" {
 		return nil
-	} else if finalStatusPhase == "Failed" {
+	} else if strings.Contains(strings.ToLower(finalStatusPhase), "failed") {
 		log.Printf("Restore failed\n")
-		return fmt.Errorf("restore failed")
+		return RestoreFailedError{Name: restoreName, Phase: finalStatusPhase}
 	}
 
 	log.Printf("Restore status is not completed or failed\n")
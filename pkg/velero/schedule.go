@@ -0,0 +1,23 @@
+package velero
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	scheduleGVR = schema.GroupVersionResource{
+		Group:    veleroApiGroup,
+		Version:  apiVersion,
+		Resource: "schedules",
+	}
+)
+
+// ListSchedules lists all Velero schedules in the specified namespace.
+func ListSchedules(dynamicClient dynamic.Interface, namespace string) (*unstructured.UnstructuredList, error) {
+	return dynamicClient.Resource(scheduleGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+}
@@ -19,7 +19,7 @@ var (
 )
 
 // EnsureSecret ensures that a Secret with the specified name and data exists in the given namespace.
-// It creates the Secret if it doesn't already exist.
+// It creates the Secret if it doesn't already exist, or renders it with redacted data in dry-run mode.
 func EnsureSecret(dynamicClient dynamic.Interface, namespace, secretName string, data map[string]string) error {
 	// List existing secrets in the namespace
 	secrets, err := dynamicClient.Resource(secretGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
@@ -31,6 +31,7 @@ func EnsureSecret(dynamicClient dynamic.Interface, namespace, secretName string,
 	for _, secret := range secrets.Items {
 		if secret.GetName() == secretName {
 			// Secret already exists, no action needed
+			recordObject("Secret", namespace, secretName, ObjectActionReused)
 			return nil
 		}
 	}
@@ -48,11 +49,21 @@ func EnsureSecret(dynamicClient dynamic.Interface, namespace, secretName string,
 		},
 	}
 
+	markManaged(secretObj)
+
+	// Only render the Secret, without its data, in dry-run mode
+	if IsDryRun() {
+		recordObject("Secret", namespace, secretName, ObjectActionCreated)
+		secretObj.Object["data"] = redactData(data)
+		return renderObject(fmt.Sprintf("Secret %s/%s would be created", namespace, secretName), secretObj.Object)
+	}
+
 	// Create the Secret in the cluster
 	_, err = dynamicClient.Resource(secretGVR).Namespace(namespace).Create(context.TODO(), secretObj, metav1.CreateOptions{})
 	if err != nil {
 		return err
 	}
+	recordObject("Secret", namespace, secretName, ObjectActionCreated)
 
 	return nil
 }
@@ -0,0 +1,203 @@
+package velero
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	common "vresq/pkg/common"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	volumeSnapshotLocationGVR = schema.GroupVersionResource{
+		Group:    veleroApiGroup,
+		Version:  apiVersion,
+		Resource: "volumesnapshotlocations",
+	}
+	// snapshotProviderClouds maps the Velero snapshot providers to the cloud provider of the nodes which can use their snapshots,
+	// as the scheme of the node provider IDs.
+	snapshotProviderClouds = map[string]string{
+		"aws":             "aws",
+		"velero.io/aws":   "aws",
+		"gcp":             "gce",
+		"velero.io/gcp":   "gce",
+		"azure":           "azure",
+		"velero.io/azure": "azure",
+	}
+)
+
+// SetupVolumeSnapshotLocations makes the VolumeSnapshotLocations of the native volume snapshots of the backup available in the destination cluster.
+// Velero restores a snapshot from the location recorded with it at backup time, so a missing location is cloned with the same name,
+// along with its credentials. Warnings are logged when the provider or the region of a location makes its snapshots unusable in the destination cluster.
+func SetupVolumeSnapshotLocations(sourceDynamicClient dynamic.Interface, destinationDynamicClient dynamic.Interface, config *common.Config) error {
+	if !config.VeleroRestoreOptions.RestorePVs {
+		log.Println("Volumes are not restored from snapshots, no VolumeSnapshotLocation is needed")
+		return nil
+	}
+	backupName := config.VeleroRestoreOptions.BackupName
+	backup, err := GetBackup(sourceDynamicClient, config.SourceVeleroNamespace, backupName)
+	if err != nil {
+		return fmt.Errorf("could not get backup %s: %v", backupName, err)
+	}
+	attempted := nestedInt64(backup.Object, "status", "volumeSnapshotsAttempted")
+	completed := nestedInt64(backup.Object, "status", "volumeSnapshotsCompleted")
+	if attempted == 0 {
+		log.Printf("Backup %s has no native volume snapshot, no VolumeSnapshotLocation is needed", backupName)
+		return nil
+	}
+	if completed < attempted {
+		log.Printf("Warning: only %d of the %d volume snapshots of backup %s completed, the other volumes cannot be restored", completed, attempted, backupName)
+	}
+
+	names := nestedStringSlice(backup.Object, "spec", "volumeSnapshotLocations")
+	if len(names) == 0 {
+		// Velero records the locations it used in the backup, older backups may not have them
+		log.Printf("Warning: backup %s does not list its VolumeSnapshotLocations, every VolumeSnapshotLocation of the source cluster is cloned", backupName)
+		locations, err := sourceDynamicClient.Resource(volumeSnapshotLocationGVR).Namespace(config.SourceVeleroNamespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("could not list VolumeSnapshotLocations in the source cluster: %v", err)
+		}
+		for _, location := range locations.Items {
+			names = append(names, location.GetName())
+		}
+	}
+	destinationNodes, err := ListNodes(destinationDynamicClient)
+	if err != nil {
+		log.Printf("Warning: could not list nodes in the destination cluster, the cloud provider and region of the snapshots are not checked: %v", err)
+		destinationNodes = []Node{}
+	}
+
+	for _, name := range names {
+		sourceLocation, err := sourceDynamicClient.Resource(volumeSnapshotLocationGVR).Namespace(config.SourceVeleroNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("could not get VolumeSnapshotLocation %s in the source cluster: %v", name, err)
+		}
+		checkSnapshotLocationCloud(sourceLocation, destinationNodes)
+
+		destinationLocation, err := destinationDynamicClient.Resource(volumeSnapshotLocationGVR).Namespace(config.DestinationVeleroNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err == nil {
+			if sameSnapshotLocation(sourceLocation, destinationLocation) {
+				log.Printf("VolumeSnapshotLocation %s already exists in the destination cluster", name)
+			} else {
+				log.Printf("Warning: VolumeSnapshotLocation %s of the destination cluster differs from the source one, the snapshots are restored with its provider %s and config",
+					name, nestedString(destinationLocation.Object, "spec", "provider"))
+			}
+			recordObject("VolumeSnapshotLocation", config.DestinationVeleroNamespace, name, ObjectActionReused)
+			continue
+		}
+		// In dry-run mode, Velero may not be installed yet in the destination cluster
+		if !apierrors.IsNotFound(err) && !IsDryRun() {
+			return fmt.Errorf("could not get VolumeSnapshotLocation %s in the destination cluster: %v", name, err)
+		}
+		log.Printf("Did not find VolumeSnapshotLocation %s in destination cluster, creating it ...", name)
+		if err := cloneVolumeSnapshotLocation(sourceDynamicClient, destinationDynamicClient, config.DestinationVeleroNamespace, sourceLocation); err != nil {
+			return fmt.Errorf("could not clone VolumeSnapshotLocation %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// cloneVolumeSnapshotLocation creates a VolumeSnapshotLocation in the destination cluster with the name and the spec of a source one,
+// and the credentials the source Velero server uses for it.
+func cloneVolumeSnapshotLocation(sourceDynamicClient dynamic.Interface, destinationDynamicClient dynamic.Interface, namespace string, sourceLocation *unstructured.Unstructured) error {
+	spec, _, err := unstructured.NestedMap(sourceLocation.Object, "spec")
+	if err != nil {
+		return err
+	}
+	credentials, key, err := getVolumeSnapshotLocationCredentials(sourceDynamicClient, sourceLocation)
+	if err != nil {
+		return err
+	}
+	secretName := fmt.Sprintf("%s-snapshot-credentials", sourceLocation.GetName())
+	if err := EnsureSecret(destinationDynamicClient, namespace, secretName, credentials); err != nil {
+		return fmt.Errorf("could not create secret for VolumeSnapshotLocation in destination cluster: %v", err)
+	}
+	spec["credential"] = map[string]interface{}{
+		"name": secretName,
+		"key":  key,
+	}
+
+	volumeSnapshotLocation := unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", veleroApiGroup, apiVersion),
+			"kind":       "VolumeSnapshotLocation",
+			"metadata": map[string]interface{}{
+				"name":      sourceLocation.GetName(),
+				"namespace": namespace,
+			},
+			"spec": spec,
+		},
+	}
+	if err := createResource(destinationDynamicClient, namespace, &volumeSnapshotLocation, volumeSnapshotLocationGVR.Resource); err != nil {
+		return err
+	} else if !IsDryRun() {
+		log.Printf("VolumeSnapshotLocation %s created successfully", sourceLocation.GetName())
+	}
+	return nil
+}
+
+// getVolumeSnapshotLocationCredentials returns the data of the Secret holding the credentials of a source VolumeSnapshotLocation, and its key:
+// the Secret of its credential when it has one, the cloud-credentials Secret of the source Velero server otherwise.
+func getVolumeSnapshotLocationCredentials(sourceDynamicClient dynamic.Interface, sourceLocation *unstructured.Unstructured) (map[string]string, string, error) {
+	if secretName := nestedString(sourceLocation.Object, "spec", "credential", "name"); secretName != "" {
+		credentials, err := GetSecret(sourceDynamicClient, sourceLocation.GetNamespace(), secretName)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not read VolumeSnapshotLocation secret %s in source cluster: %v", secretName, err)
+		}
+		return credentials, nestedString(sourceLocation.Object, "spec", "credential", "key"), nil
+	}
+
+	veleroPod, err := GetVeleroPod(sourceDynamicClient)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not get velero pod in source cluster: %v", err)
+	}
+	secretName, err := getVeleroPodSecretName(&veleroPod)
+	if err != nil {
+		return nil, "", err
+	}
+	credentials, err := GetSecret(sourceDynamicClient, veleroPod.GetNamespace(), secretName)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not retrieve velero Pod secret in source cluster: %v", err)
+	}
+	return credentials, "cloud", nil
+}
+
+// sameSnapshotLocation checks whether two VolumeSnapshotLocations have the same provider and config.
+func sameSnapshotLocation(location *unstructured.Unstructured, other *unstructured.Unstructured) bool {
+	locationConfig, _, _ := unstructured.NestedMap(location.Object, "spec", "config")
+	otherConfig, _, _ := unstructured.NestedMap(other.Object, "spec", "config")
+	return nestedString(location.Object, "spec", "provider") == nestedString(other.Object, "spec", "provider") && areMapsEqual(locationConfig, otherConfig)
+}
+
+// checkSnapshotLocationCloud warns when the snapshots of a VolumeSnapshotLocation cannot be used by the given nodes of the destination cluster:
+// snapshots are only restored in the cloud provider which took them, and regional snapshots, like EBS ones, only in their region.
+func checkSnapshotLocationCloud(location *unstructured.Unstructured, nodes []Node) {
+	providers := map[string]bool{}
+	regions := map[string]bool{}
+	for _, node := range nodes {
+		if node.Provider != "" {
+			providers[node.Provider] = true
+		}
+		if node.Region != "" {
+			regions[node.Region] = true
+		}
+	}
+
+	provider := nestedString(location.Object, "spec", "provider")
+	if cloud, known := snapshotProviderClouds[provider]; known && len(providers) > 0 && !providers[cloud] {
+		log.Printf("Warning: VolumeSnapshotLocation %s holds %s snapshots, which cannot be restored in the destination cluster running on %s",
+			location.GetName(), provider, strings.Join(sortedSet(providers), ", "))
+		return
+	}
+	region := nestedString(location.Object, "spec", "config", "region")
+	if region != "" && len(regions) > 0 && !regions[region] {
+		log.Printf("Warning: VolumeSnapshotLocation %s holds snapshots of region %s, which cannot be restored in the destination cluster running in %s: copy them to its region first",
+			location.GetName(), region, strings.Join(sortedSet(regions), ", "))
+	}
+}
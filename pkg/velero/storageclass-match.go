@@ -0,0 +1,124 @@
+package velero
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Weights of the similarities between a source and a destination storage class, the provisioner outweighing every other one.
+const (
+	provisionerMatchScore          = 100
+	volumeBindingModeMatchScore    = 10
+	reclaimPolicyMatchScore        = 10
+	allowVolumeExpansionMatchScore = 5
+	parameterMatchScore            = 1
+)
+
+// csiMigratedProvisioners maps the in-tree provisioners to the CSI drivers they are migrated to.
+var csiMigratedProvisioners = map[string]string{
+	"kubernetes.io/aws-ebs":         "ebs.csi.aws.com",
+	"kubernetes.io/gce-pd":          "pd.csi.storage.gke.io",
+	"kubernetes.io/azure-disk":      "disk.csi.azure.com",
+	"kubernetes.io/azure-file":      "file.csi.azure.com",
+	"kubernetes.io/cinder":          "cinder.csi.openstack.org",
+	"kubernetes.io/vsphere-volume":  "csi.vsphere.vmware.com",
+	"kubernetes.io/portworx-volume": "pxd.portworx.com",
+}
+
+// StorageClassMatch is a destination storage class compared to a source storage class, with the reasons it matches
+// and the warnings about the capabilities that differ.
+type StorageClassMatch struct {
+	StorageClass StorageClass `json:"storageClass" yaml:"storageClass"`
+	Reasons      []string     `json:"reasons" yaml:"reasons"`
+	Warnings     []string     `json:"warnings" yaml:"warnings"`
+	score        int
+}
+
+// CompareStorageClasses compares a destination storage class to a source storage class.
+func CompareStorageClasses(source StorageClass, destination StorageClass) StorageClassMatch {
+	match := StorageClassMatch{StorageClass: destination, Reasons: []string{}, Warnings: []string{}}
+
+	switch {
+	case source.Provisioner == destination.Provisioner:
+		match.score += provisionerMatchScore
+		match.Reasons = append(match.Reasons, fmt.Sprintf("same provisioner %s", destination.Provisioner))
+	case csiDriver(source.Provisioner) == csiDriver(destination.Provisioner):
+		match.score += provisionerMatchScore
+		match.Reasons = append(match.Reasons, fmt.Sprintf("same CSI driver %s", csiDriver(destination.Provisioner)))
+	default:
+		match.Warnings = append(match.Warnings, fmt.Sprintf("provisioner %s instead of %s, the volumes are restored on another storage backend", destination.Provisioner, source.Provisioner))
+	}
+
+	if source.VolumeBindingMode == destination.VolumeBindingMode {
+		match.score += volumeBindingModeMatchScore
+		match.Reasons = append(match.Reasons, fmt.Sprintf("same binding mode %s", destination.VolumeBindingMode))
+	} else {
+		match.Warnings = append(match.Warnings, fmt.Sprintf("binding mode %s instead of %s", destination.VolumeBindingMode, source.VolumeBindingMode))
+	}
+
+	if source.ReclaimPolicy == destination.ReclaimPolicy {
+		match.score += reclaimPolicyMatchScore
+		match.Reasons = append(match.Reasons, fmt.Sprintf("same reclaim policy %s", destination.ReclaimPolicy))
+	} else if source.ReclaimPolicy == "Retain" {
+		match.Warnings = append(match.Warnings, fmt.Sprintf("reclaim policy %s instead of Retain, the volumes are deleted along with their claims", destination.ReclaimPolicy))
+	} else {
+		match.Warnings = append(match.Warnings, fmt.Sprintf("reclaim policy %s instead of %s", destination.ReclaimPolicy, source.ReclaimPolicy))
+	}
+
+	if source.AllowVolumeExpansion == destination.AllowVolumeExpansion {
+		match.score += allowVolumeExpansionMatchScore
+	} else if source.AllowVolumeExpansion {
+		match.Warnings = append(match.Warnings, "no volume expansion, the restored volumes cannot be resized")
+	}
+
+	// Parameters are specific to a provisioner, they are only worth comparing between classes of the same one
+	if len(source.Parameters) > 0 && match.score >= provisionerMatchScore {
+		identical := 0
+		differences := []string{}
+		for _, key := range sortedStringMapKeys(source.Parameters) {
+			value, found := destination.Parameters[key]
+			switch {
+			case found && value == source.Parameters[key]:
+				identical++
+			case found:
+				differences = append(differences, fmt.Sprintf("%s %s instead of %s", key, value, source.Parameters[key]))
+			default:
+				differences = append(differences, fmt.Sprintf("no %s", key))
+			}
+		}
+		match.score += identical * parameterMatchScore
+		if identical > 0 {
+			match.Reasons = append(match.Reasons, fmt.Sprintf("%d of %d identical parameters", identical, len(source.Parameters)))
+		}
+		if len(differences) > 0 {
+			match.Warnings = append(match.Warnings, fmt.Sprintf("parameters differ: %s", strings.Join(differences, ", ")))
+		}
+	}
+	return match
+}
+
+// csiDriver returns the CSI driver a provisioner is migrated to, or the provisioner itself.
+func csiDriver(provisioner string) string {
+	if driver, found := csiMigratedProvisioners[provisioner]; found {
+		return driver
+	}
+	return provisioner
+}
+
+// SortStorageClassesByMatch compares the destination storage classes to a source storage class, from the closest to the farthest:
+// with the same provisioner, or CSI driver, then the same volume binding mode, reclaim policy, volume expansion and parameters.
+// Ties are broken by the default storage class, then by name.
+func SortStorageClassesByMatch(source StorageClass, destinationStorageClasses []StorageClass) []StorageClassMatch {
+	matches := []StorageClassMatch{}
+	for _, destination := range destinationStorageClasses {
+		matches = append(matches, CompareStorageClasses(source, destination))
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].StorageClass.Default && !matches[j].StorageClass.Default
+	})
+	return matches
+}
@@ -1,8 +1,15 @@
 package velero
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 )
 
 var (
@@ -11,14 +18,157 @@ var (
 		Version:  apiVersion,
 		Resource: "storageclasses",
 	}
+	persistentVolumeClaimGVR = schema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: "persistentvolumeclaims",
+	}
 )
 
+// betaStorageClassAnnotation is the annotation which set the storage class of volumes before spec.storageClassName.
+const betaStorageClassAnnotation = "volume.beta.kubernetes.io/storage-class"
+
+// StorageClass describes a storage class of a cluster.
+type StorageClass struct {
+	Name                 string            `json:"name" yaml:"name"`
+	Provisioner          string            `json:"provisioner" yaml:"provisioner"`
+	Parameters           map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	ReclaimPolicy        string            `json:"reclaimPolicy" yaml:"reclaimPolicy"`
+	VolumeBindingMode    string            `json:"volumeBindingMode" yaml:"volumeBindingMode"`
+	AllowVolumeExpansion bool              `json:"allowVolumeExpansion" yaml:"allowVolumeExpansion"`
+	Default              bool              `json:"default" yaml:"default"`
+}
+
+// ListStorageClasses lists the storage classes of a cluster, sorted by name.
+// Unset reclaim policies, volume binding modes and volume expansions are reported with their Kubernetes defaults.
+func ListStorageClasses(dynamicClient dynamic.Interface) ([]StorageClass, error) {
+	list, err := dynamicClient.Resource(storageClassGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	storageClasses := []StorageClass{}
+	for _, item := range list.Items {
+		parameters, _, _ := unstructured.NestedStringMap(item.Object, "parameters")
+		allowVolumeExpansion, _, _ := unstructured.NestedBool(item.Object, "allowVolumeExpansion")
+		storageClass := StorageClass{
+			Name:                 item.GetName(),
+			Provisioner:          nestedString(item.Object, "provisioner"),
+			Parameters:           parameters,
+			ReclaimPolicy:        nestedString(item.Object, "reclaimPolicy"),
+			VolumeBindingMode:    nestedString(item.Object, "volumeBindingMode"),
+			AllowVolumeExpansion: allowVolumeExpansion,
+			Default:              isDefaultStorageClass(item),
+		}
+		if storageClass.ReclaimPolicy == "" {
+			storageClass.ReclaimPolicy = "Delete"
+		}
+		if storageClass.VolumeBindingMode == "" {
+			storageClass.VolumeBindingMode = "Immediate"
+		}
+		storageClasses = append(storageClasses, storageClass)
+	}
+	sort.Slice(storageClasses, func(i, j int) bool {
+		return storageClasses[i].Name < storageClasses[j].Name
+	})
+	return storageClasses, nil
+}
+
+// DefaultStorageClass returns the name of the default storage class among the given ones, or an empty string if there is none.
+func DefaultStorageClass(storageClasses []StorageClass) string {
+	for _, storageClass := range storageClasses {
+		if storageClass.Default {
+			return storageClass.Name
+		}
+	}
+	return ""
+}
+
+// BuildStorageClassMapping maps the given source storage classes to destination storage classes: explicitly when they are in the given mapping,
+// to the default destination storage class otherwise. Storage classes which exist with the same name in the destination cluster are not mapped,
+// unless explicitly. It returns an error when an explicit mapping targets a storage class which does not exist in the destination cluster,
+// or when some storage classes are not mapped and the destination cluster has no default storage class.
+func BuildStorageClassMapping(sourceStorageClasses []string, destinationStorageClasses []StorageClass, mapping map[string]string) (map[string]string, error) {
+	destinationNames := map[string]bool{}
+	for _, storageClass := range destinationStorageClasses {
+		destinationNames[storageClass.Name] = true
+	}
+	for _, source := range sortedStringMapKeys(mapping) {
+		if !destinationNames[mapping[source]] {
+			return nil, fmt.Errorf("storage class %s is mapped to %s, which does not exist in the destination cluster", source, mapping[source])
+		}
+	}
+
+	result := map[string]string{}
+	for source, target := range mapping {
+		result[source] = target
+	}
+	defaultStorageClass := DefaultStorageClass(destinationStorageClasses)
+	unmapped := []string{}
+	for _, source := range sourceStorageClasses {
+		if _, mapped := result[source]; mapped || destinationNames[source] {
+			continue
+		}
+		if defaultStorageClass == "" {
+			unmapped = append(unmapped, source)
+			continue
+		}
+		result[source] = defaultStorageClass
+	}
+	if len(unmapped) > 0 {
+		return nil, fmt.Errorf("the destination cluster has no default storage class to map storage classes %s to, map them with --storage-class-mapping",
+			strings.Join(unmapped, ", "))
+	}
+	return result, nil
+}
+
+// volumeStorageClass returns the storage class of a PersistentVolumeClaim or a PersistentVolume, from its spec or its legacy annotation.
+func volumeStorageClass(object unstructured.Unstructured) string {
+	if storageClass := nestedString(object.Object, "spec", "storageClassName"); storageClass != "" {
+		return storageClass
+	}
+	return object.GetAnnotations()[betaStorageClassAnnotation]
+}
+
+// namespaceIncluded checks whether a namespace is restored with the given included and excluded namespaces,
+// no included namespace or "*" including every namespace.
+func namespaceIncluded(namespace string, includedNamespaces []string, excludedNamespaces []string) bool {
+	for _, excluded := range excludedNamespaces {
+		if excluded == namespace {
+			return false
+		}
+	}
+	if len(includedNamespaces) == 0 {
+		return true
+	}
+	for _, included := range includedNamespaces {
+		if included == "*" || included == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedSet returns the values of a set in alphabetical order.
+func sortedSet(values map[string]bool) []string {
+	result := make([]string, 0, len(values))
+	for value := range values {
+		result = append(result, value)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// isDefaultStorageClass checks whether a storage class is annotated as the default storage class of its cluster.
+func isDefaultStorageClass(storageClass unstructured.Unstructured) bool {
+	return storageClass.GetAnnotations()["storageclass.kubernetes.io/is-default-class"] == "true"
+}
+
 // getDestinationDefaultStorageClass retrieves the name of the default storage class from the given list of unstructured storage classes.
 // It returns an empty string if no default storage class is found.
 func getDestinationDefaultStorageClass(storageClasses []unstructured.Unstructured) string {
 	for _, storageClass := range storageClasses {
 		// Check if the storage class is marked as default
-		if storageClass.GetAnnotations()["storageclass.kubernetes.io/is-default-class"] == "true" {
+		if isDefaultStorageClass(storageClass) {
 			return storageClass.GetName()
 		}
 	}
@@ -26,12 +176,12 @@ func getDestinationDefaultStorageClass(storageClasses []unstructured.Unstructure
 	return ""
 }
 
-// getSourceStorageClassNames retrieves the names of storage classes from the given list of unstructured storage classes.
-func getSourceStorageClassNames(storageClasses []unstructured.Unstructured) []string {
-	var storageClassNames []string
-	for _, ssc := range storageClasses {
-		// Append the name of each storage class to the list
-		storageClassNames = append(storageClassNames, ssc.GetName())
+// sortedStringMapKeys returns the keys of a map in alphabetical order.
+func sortedStringMapKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
 	}
-	return storageClassNames
+	sort.Strings(keys)
+	return keys
 }
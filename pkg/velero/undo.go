@@ -0,0 +1,209 @@
+package velero
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// RestoreNameLabel is the label Velero sets on every object it restores.
+	RestoreNameLabel = "velero.io/restore-name"
+	UndoActionDelete = "delete"
+	UndoActionKeep   = "keep"
+	// maxLabelValueLength is the maximum length of a label value, longer restore names are shortened by Velero.
+	maxLabelValueLength = 63
+)
+
+// undoOrder lists resources in the order their objects are deleted: workloads first, so that they stop using
+// the configuration and volumes deleted after them, then cluster-scoped objects and namespaces last.
+// Resources which are not listed are deleted after the workloads and before the configuration.
+var undoOrder = [][]string{
+	{"cronjobs", "jobs", "deployments", "statefulsets", "daemonsets", "replicasets", "replicationcontrollers", "pods", "horizontalpodautoscalers", "poddisruptionbudgets"},
+	nil,
+	{"serviceaccounts", "rolebindings", "roles", "configmaps", "secrets", "limitranges", "resourcequotas"},
+	{"persistentvolumeclaims"},
+	{"persistentvolumes"},
+	{"clusterrolebindings", "clusterroles"},
+	{"customresourcedefinitions"},
+	{"namespaces"},
+}
+
+// RestoredObject describes an object restored by a Velero restore, and whether it is deleted to undo the restore.
+type RestoredObject struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Group     string `json:"group,omitempty" yaml:"group,omitempty"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Name      string `json:"name" yaml:"name"`
+	Action    string `json:"action" yaml:"action"`
+	gvr       schema.GroupVersionResource
+	uid       types.UID
+}
+
+// ListRestoredObjects discovers every namespaced and cluster-scoped object labelled by Velero with the given restore name,
+// in the order they should be deleted. When the Restore still exists, objects created before it started are ignored,
+// and only the targets of its namespace mapping are deleted when deleteNamespaces is set; otherwise namespaces are kept.
+func ListRestoredObjects(dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, restoreName string, restore *unstructured.Unstructured, deleteNamespaces bool) ([]RestoredObject, error) {
+	var startTime time.Time
+	mappedNamespaces := map[string]bool{}
+	if restore != nil {
+		startTimestamp := nestedString(restore.Object, "status", "startTimestamp")
+		if startTimestamp != "" {
+			var err error
+			startTime, err = time.Parse(time.RFC3339, startTimestamp)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse the start timestamp %q of restore %s: %v", startTimestamp, restoreName, err)
+			}
+		}
+		namespaceMapping, _, _ := unstructured.NestedStringMap(restore.Object, "spec", "namespaceMapping")
+		for _, target := range namespaceMapping {
+			mappedNamespaces[target] = true
+		}
+	}
+
+	resourceLists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("could not discover the API resources of the cluster: %v", err)
+	}
+	// Some aggregated APIs may be unavailable, the objects of the other ones are still listed
+
+	labelSelector := fmt.Sprintf("%s=%s", RestoreNameLabel, restoreLabelValue(restoreName))
+	objects := []RestoredObject{}
+	seen := map[types.UID]bool{}
+	for _, resourceList := range resourceLists {
+		groupVersion, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			// Subresources cannot be listed, and objects that cannot be deleted are of no interest
+			if strings.Contains(resource.Name, "/") || !hasVerbs(resource, "list", "delete") {
+				continue
+			}
+			gvr := groupVersion.WithResource(resource.Name)
+			list, err := dynamicClient.Resource(gvr).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+				LabelSelector: labelSelector,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("could not list %s labelled with %s: %v", gvr.String(), labelSelector, err)
+			}
+			for _, item := range list.Items {
+				// The same objects can be served by several groups, like events
+				if seen[item.GetUID()] {
+					continue
+				}
+				seen[item.GetUID()] = true
+				// Objects which existed before the restore started were not created by it, even if they carry its label
+				if !startTime.IsZero() && item.GetCreationTimestamp().Time.Before(startTime) {
+					continue
+				}
+				object := RestoredObject{
+					Kind:      resource.Kind,
+					Group:     groupVersion.Group,
+					Namespace: item.GetNamespace(),
+					Name:      item.GetName(),
+					Action:    UndoActionDelete,
+					gvr:       gvr,
+					uid:       item.GetUID(),
+				}
+				if gvr.Group == "" && gvr.Resource == "namespaces" && !(deleteNamespaces && mappedNamespaces[item.GetName()]) {
+					object.Action = UndoActionKeep
+				}
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		if undoPriority(objects[i].gvr.Resource) != undoPriority(objects[j].gvr.Resource) {
+			return undoPriority(objects[i].gvr.Resource) < undoPriority(objects[j].gvr.Resource)
+		}
+		if objects[i].Kind != objects[j].Kind {
+			return objects[i].Kind < objects[j].Kind
+		}
+		if objects[i].Namespace != objects[j].Namespace {
+			return objects[i].Namespace < objects[j].Namespace
+		}
+		return objects[i].Name < objects[j].Name
+	})
+	return objects, nil
+}
+
+// DeleteRestoredObject deletes an object restored by a Velero restore, along with its dependents.
+// Objects already deleted, for example by the garbage collector after their owner, are ignored.
+func DeleteRestoredObject(dynamicClient dynamic.Interface, object RestoredObject) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	err := dynamicClient.Resource(object.gvr).Namespace(object.Namespace).Delete(context.TODO(), object.Name, metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+		// Never delete an object recreated with the same name since the inventory
+		Preconditions: &metav1.Preconditions{UID: &object.uid},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("could not delete %s %s: %v", object.Kind, objectKey(object.Namespace, object.Name), err)
+	}
+	return nil
+}
+
+// undoPriority returns the position of a resource in the deletion order.
+func undoPriority(resource string) int {
+	defaultPriority := 0
+	for priority, resources := range undoOrder {
+		if resources == nil {
+			defaultPriority = priority
+			continue
+		}
+		for _, name := range resources {
+			if name == resource {
+				return priority
+			}
+		}
+	}
+	return defaultPriority
+}
+
+// hasVerbs checks whether an API resource supports all the given verbs.
+func hasVerbs(resource metav1.APIResource, verbs ...string) bool {
+	for _, verb := range verbs {
+		found := false
+		for _, supportedVerb := range resource.Verbs {
+			if supportedVerb == verb {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreLabelValue returns the value of the restore name label set by Velero.
+// Like Velero, names longer than a label value are truncated and suffixed with the beginning of their SHA-256 hash.
+func restoreLabelValue(restoreName string) string {
+	if len(restoreName) <= maxLabelValueLength {
+		return restoreName
+	}
+	hash := sha256.Sum256([]byte(restoreName))
+	return restoreName[:maxLabelValueLength-6] + hex.EncodeToString(hash[:])[:6]
+}
+
+// objectKey formats the namespace and name of an object, or only its name when it is cluster-scoped.
+func objectKey(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
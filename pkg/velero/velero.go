@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	common "vresq/pkg/common"
 
 	helm "github.com/mittwald/go-helm-client"
 	"gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/client-go/dynamic"
 )
 
@@ -43,7 +47,6 @@ const (
 	ConfirmNo      = common.ConfirmNo
 	veleroApiGroup = common.VeleroApiGroup
 	apiVersion     = common.ApiVersion
-	configMapName  = common.ConfigMapName
 )
 
 // mapToYAML converts a map to YAML format.
@@ -137,19 +140,103 @@ func areMapsEqual(map1, map2 map[string]interface{}) bool {
 	return true
 }
 
-// parseOrLabels parses the input map into a slice of map[string]map[string]string suitable for label selector.
-func parseOrLabels(inputMap map[string]string) []map[string]map[string]string {
-	var result []map[string]map[string]string
+// orSelectorSeparator separates the OR label selectors given in a single value, as the velero CLI does.
+const orSelectorSeparator = " or "
 
-	for key, value := range inputMap {
-		result = append(result, map[string]map[string]string{"matchLabels": {key: value}})
+// OrLabelSelectorTerms returns the OR label selectors of the restore options, one selector per term.
+// A value may hold several selectors separated by " or ", empty values are ignored.
+func OrLabelSelectorTerms(options common.VeleroRestoreOptions) []string {
+	terms := []string{}
+	for _, value := range options.OrLabelSelectors {
+		for _, term := range strings.Split(value, orSelectorSeparator) {
+			if term = strings.TrimSpace(term); term != "" {
+				terms = append(terms, term)
+			}
+		}
 	}
+	return terms
+}
 
+// ValidateLabelSelectors checks that the label selector and the OR label selectors of the restore options are valid Kubernetes selectors,
+// and that they are not both given, which Velero rejects.
+func ValidateLabelSelectors(options common.VeleroRestoreOptions) error {
+	var errs []error
+	if _, err := parseLabelSelector(options.LabelSelector); err != nil {
+		errs = append(errs, fmt.Errorf("invalid label selector %q: %v", options.LabelSelector, err))
+	}
+	orTerms := OrLabelSelectorTerms(options)
+	for _, term := range orTerms {
+		if _, err := parseLabelSelector(term); err != nil {
+			errs = append(errs, fmt.Errorf("invalid OR label selector %q: %v", term, err))
+		}
+	}
+	if strings.TrimSpace(options.LabelSelector) != "" && len(orTerms) > 0 {
+		errs = append(errs, errors.New("a label selector and OR label selectors cannot be given together"))
+	}
+	return errors.Join(errs...)
+}
+
+// parseLabelSelector parses a Kubernetes selector string, like "app=shop,env in (prod,staging),!canary",
+// into a label selector with matchLabels and matchExpressions. It returns nil for an empty selector.
+func parseLabelSelector(selector string) (map[string]interface{}, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+	requirements, err := labels.ParseToRequirements(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	labelSelector := &metav1.LabelSelector{}
+	for _, requirement := range requirements {
+		var operator metav1.LabelSelectorOperator
+		switch requirement.Operator() {
+		case selection.Equals, selection.DoubleEquals:
+			if labelSelector.MatchLabels == nil {
+				labelSelector.MatchLabels = map[string]string{}
+			}
+			labelSelector.MatchLabels[requirement.Key()] = requirement.Values().List()[0]
+			continue
+		case selection.In:
+			operator = metav1.LabelSelectorOpIn
+		case selection.NotIn, selection.NotEquals:
+			operator = metav1.LabelSelectorOpNotIn
+		case selection.Exists:
+			operator = metav1.LabelSelectorOpExists
+		case selection.DoesNotExist:
+			operator = metav1.LabelSelectorOpDoesNotExist
+		default:
+			return nil, fmt.Errorf("operator %q is not supported by label selectors", requirement.Operator())
+		}
+		labelSelector.MatchExpressions = append(labelSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      requirement.Key(),
+			Operator: operator,
+			Values:   requirement.Values().List(),
+		})
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(labelSelector)
+}
+
+// parseOrLabelSelectors parses the OR label selectors of the restore options. Invalid selectors are left out,
+// they are reported by ValidateLabelSelectors.
+func parseOrLabelSelectors(options common.VeleroRestoreOptions) []interface{} {
+	var result []interface{}
+	for _, term := range OrLabelSelectorTerms(options) {
+		if labelSelector, err := parseLabelSelector(term); err == nil {
+			result = append(result, labelSelector)
+		}
+	}
 	return result
 }
 
-// createResource creates a Kubernetes resource.
+// createResource creates a Kubernetes resource, marked as managed by vresq.
+// In dry-run mode, the resource is only rendered.
 func createResource(dynamicClient dynamic.Interface, namespace string, resource *unstructured.Unstructured, r string) error {
+	markManaged(resource)
+	if IsDryRun() {
+		recordObject(resource.GetKind(), namespace, resource.GetName(), ObjectActionCreated)
+		return renderObject(fmt.Sprintf("%s %s/%s would be created", resource.GetKind(), namespace, resource.GetName()), resource.Object)
+	}
 	_, err := dynamicClient.Resource(schema.GroupVersionResource{
 		Group:    resource.GroupVersionKind().Group,
 		Version:  resource.GroupVersionKind().Version,
@@ -158,6 +245,25 @@ func createResource(dynamicClient dynamic.Interface, namespace string, resource
 	if err != nil {
 		return fmt.Errorf("failed to create resource: %v", err)
 	}
+	recordObject(resource.GetKind(), namespace, resource.GetName(), ObjectActionCreated)
 
 	return nil
 }
+
+// nestedString returns the string value of a nested field, or an empty string if it is missing or not a string.
+func nestedString(obj map[string]interface{}, fields ...string) string {
+	value, _, _ := unstructured.NestedString(obj, fields...)
+	return value
+}
+
+// nestedInt64 returns the integer value of a nested field, or 0 if it is missing or not an integer.
+func nestedInt64(obj map[string]interface{}, fields ...string) int64 {
+	value, _, _ := unstructured.NestedInt64(obj, fields...)
+	return value
+}
+
+// nestedStringSlice returns the string slice value of a nested field, or nil if it is missing or not a string slice.
+func nestedStringSlice(obj map[string]interface{}, fields ...string) []string {
+	value, _, _ := unstructured.NestedStringSlice(obj, fields...)
+	return value
+}
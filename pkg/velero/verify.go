@@ -0,0 +1,231 @@
+package velero
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+	common "vresq/pkg/common"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	WorkloadHealthy = "Healthy"
+	WorkloadPending = "Pending"
+	WorkloadFailed  = "Failed"
+	// verifyPollInterval is the time between two checks of the restored workloads.
+	verifyPollInterval = 5 * time.Second
+)
+
+// verifiedResource describes a kind of restored object whose health is verified, and how.
+type verifiedResource struct {
+	kind  string
+	gvr   schema.GroupVersionResource
+	check func(object unstructured.Unstructured) (string, string)
+}
+
+var verifiedResources = []verifiedResource{
+	{kind: "Deployment", gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, check: checkDeployment},
+	{kind: "StatefulSet", gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, check: checkStatefulSet},
+	{kind: "DaemonSet", gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, check: checkDaemonSet},
+	{kind: "PersistentVolumeClaim", gvr: schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}, check: checkPersistentVolumeClaim},
+	{kind: "Job", gvr: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, check: checkJob},
+}
+
+// WorkloadStatus is the health of a restored workload.
+type WorkloadStatus struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	Status    string `json:"status" yaml:"status"`
+	Message   string `json:"message" yaml:"message"`
+}
+
+// RestoreTargetNamespaces returns the destination namespaces of a restore: the targets of the namespace mapping
+// and the included namespaces which are not mapped. It returns nil when the restore includes every namespace.
+func RestoreTargetNamespaces(options common.VeleroRestoreOptions) []string {
+	namespaces := map[string]bool{}
+	for _, target := range options.NamespaceMapping {
+		namespaces[target] = true
+	}
+	for _, namespace := range options.IncludedNamespaces {
+		if namespace == "*" || namespace == "" {
+			if len(options.NamespaceMapping) == 0 {
+				return nil
+			}
+			continue
+		}
+		if target, mapped := options.NamespaceMapping[namespace]; mapped {
+			namespace = target
+		}
+		namespaces[namespace] = true
+	}
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	result := make([]string, 0, len(namespaces))
+	for namespace := range namespaces {
+		result = append(result, namespace)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// VerifyRestoredWorkloads waits until the Deployments, StatefulSets and DaemonSets restored by a restore in the given namespaces
+// have ready replicas, their PersistentVolumeClaims are Bound and their Jobs have succeeded.
+// Every namespace is verified when namespaces is empty. It returns the last status of every workload,
+// and an error if one of them failed or was still not healthy after the timeout.
+func VerifyRestoredWorkloads(dynamicClient dynamic.Interface, restoreName string, namespaces []string, timeout time.Duration) ([]WorkloadStatus, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	log.Printf("Verifying the health of the workloads restored by restore %s, for up to %s", restoreName, timeout)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		statuses, err := getWorkloadStatuses(dynamicClient, restoreName, namespaces)
+		if err != nil {
+			return nil, err
+		}
+
+		healthy, failed := 0, 0
+		for _, status := range statuses {
+			switch status.Status {
+			case WorkloadHealthy:
+				healthy++
+			case WorkloadFailed:
+				failed++
+			}
+		}
+		log.Printf("%d of %d restored workloads healthy", healthy, len(statuses))
+		if failed > 0 {
+			return statuses, fmt.Errorf("%d of %d restored workloads failed", failed, len(statuses))
+		}
+		if healthy == len(statuses) {
+			return statuses, nil
+		}
+		if time.Now().After(deadline) {
+			return statuses, fmt.Errorf("%d of %d restored workloads are still not healthy after %s", len(statuses)-healthy, len(statuses), timeout)
+		}
+		time.Sleep(verifyPollInterval)
+	}
+}
+
+// getWorkloadStatuses returns the current status of the workloads restored by a restore in the given namespaces.
+func getWorkloadStatuses(dynamicClient dynamic.Interface, restoreName string, namespaces []string) ([]WorkloadStatus, error) {
+	labelSelector := fmt.Sprintf("%s=%s", RestoreNameLabel, restoreLabelValue(restoreName))
+	statuses := []WorkloadStatus{}
+	for _, resource := range verifiedResources {
+		for _, namespace := range namespaces {
+			list, err := dynamicClient.Resource(resource.gvr).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+				LabelSelector: labelSelector,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("could not list %s restored by restore %s: %v", resource.gvr.Resource, restoreName, err)
+			}
+			for _, item := range list.Items {
+				status, message := resource.check(item)
+				statuses = append(statuses, WorkloadStatus{
+					Kind:      resource.kind,
+					Namespace: item.GetNamespace(),
+					Name:      item.GetName(),
+					Status:    status,
+					Message:   message,
+				})
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// desiredReplicas returns the number of replicas of a workload, which defaults to 1.
+func desiredReplicas(object unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(object.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
+
+// checkDeployment checks whether every replica of a Deployment is updated and ready.
+func checkDeployment(object unstructured.Unstructured) (string, string) {
+	replicas := desiredReplicas(object)
+	ready := nestedInt64(object.Object, "status", "readyReplicas")
+	updated := nestedInt64(object.Object, "status", "updatedReplicas")
+	message := fmt.Sprintf("%d/%d replicas ready", ready, replicas)
+	if nestedInt64(object.Object, "status", "observedGeneration") < object.GetGeneration() || updated < replicas || ready < replicas {
+		return WorkloadPending, message
+	}
+	return WorkloadHealthy, message
+}
+
+// checkStatefulSet checks whether every replica of a StatefulSet is ready.
+func checkStatefulSet(object unstructured.Unstructured) (string, string) {
+	replicas := desiredReplicas(object)
+	ready := nestedInt64(object.Object, "status", "readyReplicas")
+	message := fmt.Sprintf("%d/%d replicas ready", ready, replicas)
+	if nestedInt64(object.Object, "status", "observedGeneration") < object.GetGeneration() || ready < replicas {
+		return WorkloadPending, message
+	}
+	return WorkloadHealthy, message
+}
+
+// checkDaemonSet checks whether the pods of a DaemonSet are ready on every node they are scheduled on.
+func checkDaemonSet(object unstructured.Unstructured) (string, string) {
+	desired := nestedInt64(object.Object, "status", "desiredNumberScheduled")
+	ready := nestedInt64(object.Object, "status", "numberReady")
+	message := fmt.Sprintf("%d/%d pods ready", ready, desired)
+	if nestedInt64(object.Object, "status", "observedGeneration") < object.GetGeneration() || ready < desired {
+		return WorkloadPending, message
+	}
+	return WorkloadHealthy, message
+}
+
+// checkPersistentVolumeClaim checks whether a PersistentVolumeClaim is bound to a volume.
+func checkPersistentVolumeClaim(object unstructured.Unstructured) (string, string) {
+	phase := nestedString(object.Object, "status", "phase")
+	switch phase {
+	case "Bound":
+		return WorkloadHealthy, phase
+	case "Lost":
+		return WorkloadFailed, phase
+	case "":
+		return WorkloadPending, "Pending"
+	default:
+		return WorkloadPending, phase
+	}
+}
+
+// checkJob checks whether a Job has succeeded.
+func checkJob(object unstructured.Unstructured) (string, string) {
+	completions, found, _ := unstructured.NestedInt64(object.Object, "spec", "completions")
+	if !found {
+		completions = 1
+	}
+	succeeded := nestedInt64(object.Object, "status", "succeeded")
+	message := fmt.Sprintf("%d/%d completions succeeded", succeeded, completions)
+
+	conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok || conditionMap["status"] != "True" {
+			continue
+		}
+		switch conditionMap["type"] {
+		case "Complete":
+			return WorkloadHealthy, message
+		case "Failed":
+			return WorkloadFailed, fmt.Sprintf("%s: %s", message, nestedString(conditionMap, "message"))
+		}
+	}
+	if succeeded >= completions {
+		return WorkloadHealthy, message
+	}
+	return WorkloadPending, message
+}